@@ -20,6 +20,15 @@ import (
 // @version 1.0
 // @description A RESTful API for a clothes shop built with Golang and Gin.
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the JWT token.
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key issued by an admin, for server-to-server integrations.
 func main() {
 
 	// Load .env (local only)
@@ -34,6 +43,15 @@ func main() {
 	// Create Gin server
 	r := gin.Default()
 
+	// Only believe X-Forwarded-For from our own proxies, so clients cannot choose
+	// the IP that API key allow lists and login throttling see
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("❌ Invalid TRUSTED_PROXIES:", err)
+	}
+	if len(cfg.TrustedProxies) == 0 && cfg.LoginMaxIPFailures > 0 {
+		log.Println("⚠️ TRUSTED_PROXIES is empty: behind a reverse proxy all clients share its IP and LOGIN_MAX_IP_FAILURES locks out everyone; set TRUSTED_PROXIES or LOGIN_MAX_IP_FAILURES=0")
+	}
+
 	// CORS (open for demo)
 	r.Use(cors.Default())
 
@@ -50,7 +68,7 @@ func main() {
 	})
 
 	// Setup API routes
-	routes.SetupRoutes(r, cfg.JWTSecret)
+	routes.SetupRoutes(r, cfg)
 
 	// =============================
 	// 🔥 DYNAMIC PORT (Render)
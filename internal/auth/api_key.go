@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"net"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key so leaked keys are easy to recognise.
+const APIKeyPrefix = "csk_"
+
+// apiKeyScopes are the permissions that can be granted to API keys. Managing
+// users always requires a signed-in admin.
+var apiKeyScopes = []Permission{PermCatalogWrite, PermOrdersManage}
+
+// GenerateAPIKey returns a new API key, the short prefix shown in listings and
+// the hash to store.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	token, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = APIKeyPrefix + token
+	return key, key[:len(APIKeyPrefix)+6], HashToken(key), nil
+}
+
+// IsValidAPIKeyScope reports whether scope may be granted to an API key.
+func IsValidAPIKeyScope(scope string) bool {
+	for _, p := range apiKeyScopes {
+		if string(p) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidIPRule reports whether rule is an IP address or a CIDR range.
+func IsValidIPRule(rule string) bool {
+	if strings.Contains(rule, "/") {
+		_, _, err := net.ParseCIDR(rule)
+		return err == nil
+	}
+	return net.ParseIP(rule) != nil
+}
+
+// IPAllowed reports whether ip matches one of rules. An empty list allows every address.
+func IPAllowed(rules []string, ip string) bool {
+	if len(rules) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, rule := range rules {
+		if strings.Contains(rule, "/") {
+			if _, network, err := net.ParseCIDR(rule); err == nil && network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(rule); allowed != nil && allowed.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
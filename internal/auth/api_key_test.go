@@ -0,0 +1,52 @@
+package auth
+
+import "testing"
+
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		ip    string
+		want  bool
+	}{
+		{"no rules", nil, "203.0.113.7", true},
+		{"exact address", []string{"203.0.113.7"}, "203.0.113.7", true},
+		{"other address", []string{"203.0.113.7"}, "203.0.113.8", false},
+		{"inside range", []string{"10.0.0.0/8"}, "10.20.30.40", true},
+		{"outside range", []string{"10.0.0.0/8"}, "11.0.0.1", false},
+		{"any rule", []string{"203.0.113.7", "192.168.0.0/16"}, "192.168.1.1", true},
+		{"ipv6 range", []string{"2001:db8::/32"}, "2001:db8::1", true},
+		{"ipv4-mapped ipv6", []string{"203.0.113.7"}, "::ffff:203.0.113.7", true},
+		{"invalid rules are ignored", []string{"not-an-ip", "10.0.0.0/33"}, "10.0.0.1", false},
+		{"invalid address", []string{"10.0.0.0/8"}, "10.0.0", false},
+		{"empty address", []string{"10.0.0.0/8"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IPAllowed(tt.rules, tt.ip); got != tt.want {
+				t.Errorf("IPAllowed(%q, %q) = %t, want %t", tt.rules, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidIPRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"203.0.113.7", true},
+		{"10.0.0.0/8", true},
+		{"2001:db8::/32", true},
+		{"10.0.0.0/33", false},
+		{"example.com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidIPRule(tt.rule); got != tt.want {
+			t.Errorf("IsValidIPRule(%q) = %t, want %t", tt.rule, got, tt.want)
+		}
+	}
+}
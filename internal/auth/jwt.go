@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token uses distinguish access tokens from short-lived tokens that only unlock
+// one step of a flow.
+const (
+	TokenUseAccess       = "access"
+	TokenUseMFAChallenge = "mfa_challenge"
+)
+
+// Claims is the payload carried by tokens issued by the API.
+type Claims struct {
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	// MFA is true when the session was authenticated with a second factor.
+	MFA      bool   `json:"mfa"`
+	TokenUse string `json:"token_use"`
+	// ImpersonatedBy is the ID of the admin acting as this user, if any.
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken signs an HS256 token carrying claims that expires after ttl.
+func GenerateToken(secret string, claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// ParseToken validates the signature and expiry of a token issued for use and returns its claims.
+func ParseToken(secret, tokenString, use string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.UserID == "" || claims.TokenUse != use {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"clothes-shop-api/internal/models"
+)
+
+// LoginAttemptStore persists failed-login counters. Implementations must make
+// RecordFailure atomic so that concurrent attempts are all counted.
+type LoginAttemptStore interface {
+	// GetAttempt returns the counter for key, or nil if there is none.
+	GetAttempt(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordFailure increments the counter for key. Failures older than window
+	// are forgotten and the count restarts at 1.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+	// Lock blocks logins for key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset clears the counter and any lock for key.
+	Reset(ctx context.Context, key string) error
+}
+
+// LoginGuardConfig controls throttling and lockout.
+type LoginGuardConfig struct {
+	// FreeAttempts is the number of failures allowed before delays start.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure beyond FreeAttempts; it
+	// doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxAccountFailures and MaxIPFailures lock the account or IP once reached.
+	// With MaxIPFailures at 0 failures are not tracked per IP at all, for
+	// deployments where the client IP is not known.
+	MaxAccountFailures int
+	MaxIPFailures      int
+	// LockoutDuration is how long a lock lasts and how long failures are remembered.
+	LockoutDuration time.Duration
+}
+
+// LoginCheck is the outcome of LoginGuard.Check.
+type LoginCheck struct {
+	// Locked is true when the account or IP is locked out.
+	Locked bool
+	// RetryAfter is how long the client must wait; zero means the attempt may proceed.
+	RetryAfter time.Duration
+}
+
+// LoginLockout describes a lock applied by RecordFailure.
+type LoginLockout struct {
+	EventType   string
+	Failures    int
+	LockedUntil time.Time
+}
+
+// LoginGuard applies progressive delays and temporary lockouts to logins,
+// tracked separately per account email and per client IP.
+type LoginGuard struct {
+	store LoginAttemptStore
+	cfg   LoginGuardConfig
+}
+
+func NewLoginGuard(store LoginAttemptStore, cfg LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{store: store, cfg: cfg}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check reports whether a login for email from ip may be attempted now.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) (LoginCheck, error) {
+	now := time.Now().UTC()
+	var result LoginCheck
+
+	keys := []string{accountKey(email)}
+	if g.cfg.MaxIPFailures > 0 {
+		keys = append(keys, ipKey(ip))
+	}
+
+	for _, key := range keys {
+		attempt, err := g.store.GetAttempt(ctx, key)
+		if err != nil {
+			return LoginCheck{}, err
+		}
+		if attempt == nil {
+			continue
+		}
+
+		if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+			result.Locked = true
+			if wait := attempt.LockedUntil.Sub(now); wait > result.RetryAfter {
+				result.RetryAfter = wait
+			}
+			continue
+		}
+
+		if now.Sub(attempt.LastFailureAt) > g.cfg.LockoutDuration {
+			continue
+		}
+
+		if wait := attempt.LastFailureAt.Add(g.delay(attempt.Failures)).Sub(now); wait > result.RetryAfter {
+			result.RetryAfter = wait
+		}
+	}
+
+	return result, nil
+}
+
+// delay returns the wait required after the given number of consecutive failures.
+func (g *LoginGuard) delay(failures int) time.Duration {
+	extra := failures - g.cfg.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+
+	d := g.cfg.BaseDelay
+	for i := 1; i < extra && d < g.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.cfg.MaxDelay {
+		d = g.cfg.MaxDelay
+	}
+	return d
+}
+
+// RecordFailure counts a failed login and returns the lockouts it triggered, if any.
+func (g *LoginGuard) RecordFailure(ctx context.Context, email, ip string) ([]LoginLockout, error) {
+	now := time.Now().UTC()
+	var lockouts []LoginLockout
+
+	type counter struct {
+		key       string
+		max       int
+		eventType string
+	}
+	checks := []counter{{accountKey(email), g.cfg.MaxAccountFailures, models.SecurityEventAccountLocked}}
+	if g.cfg.MaxIPFailures > 0 {
+		checks = append(checks, counter{ipKey(ip), g.cfg.MaxIPFailures, models.SecurityEventIPLocked})
+	}
+
+	for _, check := range checks {
+		attempt, err := g.store.RecordFailure(ctx, check.key, now, g.cfg.LockoutDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		if check.max > 0 && attempt.Failures >= check.max {
+			until := now.Add(g.cfg.LockoutDuration)
+			if err := g.store.Lock(ctx, check.key, until); err != nil {
+				return nil, err
+			}
+			lockouts = append(lockouts, LoginLockout{EventType: check.eventType, Failures: attempt.Failures, LockedUntil: until})
+		}
+	}
+
+	return lockouts, nil
+}
+
+// RecordSuccess clears the failure count of the account. The IP counter is kept
+// so that a valid login cannot be used to reset guessing against other accounts.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, accountKey(email))
+}
+
+// Unlock clears any lock and failure count of the account.
+func (g *LoginGuard) Unlock(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, accountKey(email))
+}
+
+// UnlockIP clears any lock and failure count of an IP address.
+func (g *LoginGuard) UnlockIP(ctx context.Context, ip string) error {
+	return g.store.Reset(ctx, ipKey(ip))
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"clothes-shop-api/internal/models"
+)
+
+var testGuardConfig = LoginGuardConfig{
+	FreeAttempts:       2,
+	BaseDelay:          time.Minute,
+	MaxDelay:           4 * time.Minute,
+	MaxAccountFailures: 6,
+	MaxIPFailures:      8,
+	LockoutDuration:    time.Hour,
+}
+
+func TestLoginGuardDelay(t *testing.T) {
+	g := NewLoginGuard(NewMemoryLoginAttemptStore(), testGuardConfig)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{20, 4 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := g.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  LoginGuardConfig
+		// failures are recorded for the email and IP before the check.
+		failures int
+		// success is recorded after the failures when set.
+		success    bool
+		email      string
+		ip         string
+		wantLocked bool
+		wantDelay  time.Duration
+		wantEvents []string
+	}{
+		{name: "free attempts", cfg: testGuardConfig, failures: 2, email: "a@x.com", ip: "192.0.2.1"},
+		{name: "delay after the free attempts", cfg: testGuardConfig, failures: 3, email: "a@x.com", ip: "192.0.2.1", wantDelay: time.Minute},
+		{name: "delay is capped", cfg: testGuardConfig, failures: 5, email: "a@x.com", ip: "192.0.2.1", wantDelay: 4 * time.Minute},
+		{
+			name: "account lockout", cfg: testGuardConfig, failures: 6, email: "a@x.com", ip: "192.0.2.1",
+			wantLocked: true, wantDelay: time.Hour, wantEvents: []string{models.SecurityEventAccountLocked},
+		},
+		{
+			name: "other account from another IP", cfg: testGuardConfig, failures: 6, email: "b@x.com", ip: "192.0.2.2",
+			wantEvents: []string{models.SecurityEventAccountLocked},
+		},
+		{name: "email is not case-sensitive", cfg: testGuardConfig, failures: 3, email: " A@X.com", ip: "192.0.2.2", wantDelay: time.Minute},
+		{name: "IP delay for another account", cfg: testGuardConfig, failures: 3, email: "b@x.com", ip: "192.0.2.1", wantDelay: time.Minute},
+		{name: "success clears the account but not the IP", cfg: testGuardConfig, failures: 3, success: true, email: "a@x.com", ip: "192.0.2.1", wantDelay: time.Minute},
+		{name: "success from another IP", cfg: testGuardConfig, failures: 3, success: true, email: "a@x.com", ip: "192.0.2.2"},
+		{
+			name: "no IP tracking", cfg: LoginGuardConfig{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: 4 * time.Minute, LockoutDuration: time.Hour},
+			failures: 3, success: true, email: "a@x.com", ip: "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g := NewLoginGuard(NewMemoryLoginAttemptStore(), tt.cfg)
+
+			var events []string
+			for i := 0; i < tt.failures; i++ {
+				lockouts, err := g.RecordFailure(ctx, "a@x.com", "192.0.2.1")
+				if err != nil {
+					t.Fatalf("RecordFailure: %v", err)
+				}
+				for _, lockout := range lockouts {
+					events = append(events, lockout.EventType)
+				}
+			}
+			if tt.success {
+				if err := g.RecordSuccess(ctx, "a@x.com"); err != nil {
+					t.Fatalf("RecordSuccess: %v", err)
+				}
+			}
+
+			if len(events) != len(tt.wantEvents) || (len(events) > 0 && events[0] != tt.wantEvents[0]) {
+				t.Errorf("lockout events = %v, want %v", events, tt.wantEvents)
+			}
+
+			check, err := g.Check(ctx, tt.email, tt.ip)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if check.Locked != tt.wantLocked {
+				t.Errorf("Locked = %t, want %t", check.Locked, tt.wantLocked)
+			}
+			// The check runs a moment after the last failure
+			if check.RetryAfter > tt.wantDelay || check.RetryAfter < tt.wantDelay-time.Second {
+				t.Errorf("RetryAfter = %v, want %v", check.RetryAfter, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestLoginGuardIPLockoutAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	g := NewLoginGuard(NewMemoryLoginAttemptStore(), testGuardConfig)
+
+	// Guessing spread over accounts still locks the IP
+	var locked bool
+	for i := 0; i < testGuardConfig.MaxIPFailures; i++ {
+		lockouts, err := g.RecordFailure(ctx, string(rune('a'+i))+"@x.com", "192.0.2.1")
+		if err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+		for _, lockout := range lockouts {
+			locked = locked || lockout.EventType == models.SecurityEventIPLocked
+		}
+	}
+	if !locked {
+		t.Fatalf("IP was not locked after %d failures", testGuardConfig.MaxIPFailures)
+	}
+
+	if check, _ := g.Check(ctx, "new@x.com", "192.0.2.1"); !check.Locked {
+		t.Errorf("Check from the locked IP: Locked = false, want true")
+	}
+	if check, _ := g.Check(ctx, "new@x.com", "192.0.2.2"); check.Locked || check.RetryAfter != 0 {
+		t.Errorf("Check from another IP = %+v, want no wait", check)
+	}
+
+	if err := g.UnlockIP(ctx, "192.0.2.1"); err != nil {
+		t.Fatalf("UnlockIP: %v", err)
+	}
+	if check, _ := g.Check(ctx, "new@x.com", "192.0.2.1"); check.Locked || check.RetryAfter != 0 {
+		t.Errorf("Check after UnlockIP = %+v, want no wait", check)
+	}
+}
+
+func TestMemoryLoginAttemptStoreEvictsExpiredCounters(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryLoginAttemptStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := time.Minute
+
+	if _, err := s.RecordFailure(ctx, "old", start, window); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "locked", start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Within the window nothing is swept, past it expired counters are
+	if _, err := s.RecordFailure(ctx, "new", start.Add(30*time.Second), window); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := s.GetAttempt(ctx, "old"); attempt == nil {
+		t.Fatal("counter was evicted within its window")
+	}
+
+	if _, err := s.RecordFailure(ctx, "new", start.Add(2*window), window); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _ := s.GetAttempt(ctx, "old"); attempt != nil {
+		t.Error("expired counter was not evicted")
+	}
+	if attempt, _ := s.GetAttempt(ctx, "locked"); attempt == nil {
+		t.Error("counter of an active lock was evicted")
+	}
+	if attempt, _ := s.GetAttempt(ctx, "new"); attempt == nil || attempt.Failures != 1 {
+		t.Errorf("counter = %+v, want 1 failure after its window restarted", attempt)
+	}
+}
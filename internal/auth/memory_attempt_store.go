@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"clothes-shop-api/internal/models"
+)
+
+// MemoryLoginAttemptStore keeps login counters in process memory. It is only
+// suitable when a single API instance is running.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+	// nextEviction is when expired counters are dropped next.
+	nextEviction time.Time
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: make(map[string]models.LoginAttempt)}
+}
+
+func (s *MemoryLoginAttemptStore) GetAttempt(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || now.Sub(attempt.LastFailureAt) > window {
+		attempt = models.LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	s.attempts[key] = attempt
+
+	if !now.Before(s.nextEviction) {
+		s.evictExpired(now, window)
+		s.nextEviction = now.Add(window)
+	}
+
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.attempts[key]
+	attempt.Key = key
+	attempt.LockedUntil = &until
+	s.attempts[key] = attempt
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// evictExpired drops counters that can no longer affect a login so the map does
+// not grow without bound. RecordFailure runs it at most once per window, so a
+// counter outlives its window by one window at most. The caller must hold s.mu.
+func (s *MemoryLoginAttemptStore) evictExpired(now time.Time, window time.Duration) {
+	for key, attempt := range s.attempts {
+		if now.Sub(attempt.LastFailureAt) <= window {
+			continue
+		}
+		if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+			continue
+		}
+		delete(s.attempts, key)
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+// Roles a user account can hold.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// Permission names an action that a role may be allowed to perform.
+type Permission string
+
+const (
+	PermCatalogWrite Permission = "catalog:write"
+	PermOrdersPlace  Permission = "orders:place"
+	PermOrdersManage Permission = "orders:manage"
+	PermUsersManage  Permission = "users:manage"
+)
+
+// rolePermissions is the permission matrix for every known role.
+var rolePermissions = map[string][]Permission{
+	RoleCustomer: {PermOrdersPlace},
+	RoleStaff:    {PermOrdersPlace, PermCatalogWrite, PermOrdersManage},
+	RoleAdmin:    {PermOrdersPlace, PermCatalogWrite, PermOrdersManage, PermUsersManage},
+}
+
+// IsValidRole reports whether role is one of the fixed roles.
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role is granted perm.
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
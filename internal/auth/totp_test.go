@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238, "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B lists 8-digit codes; these are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		counter, ok := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("ValidateTOTP(%q) at %d = false, want true", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; counter != want {
+			t.Errorf("ValidateTOTP(%q) at %d counter = %d, want %d", tt.code, tt.unix, counter, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		want   bool
+	}{
+		{"current period", rfc6238Secret, "050471", at, true},
+		{"spaces and lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", " 050 471 ", at, true},
+		{"previous period", rfc6238Secret, "050471", at.Add(totpPeriod * time.Second), true},
+		{"outside the skew", rfc6238Secret, "050471", at.Add(3 * totpPeriod * time.Second), false},
+		{"wrong code", rfc6238Secret, "050472", at, false},
+		{"too short", rfc6238Secret, "05047", at, false},
+		{"invalid secret", "not base32!", "050471", at, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := ValidateTOTP(tt.secret, tt.code, tt.at); got != tt.want {
+				t.Errorf("ValidateTOTP(%q, %q) = %t, want %t", tt.secret, tt.code, got, tt.want)
+			}
+		})
+	}
+}
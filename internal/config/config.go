@@ -5,6 +5,9 @@ import (
 	"crypto/tls"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -12,7 +15,61 @@ import (
 var DB *pgxpool.Pool
 
 type Config struct {
-	JWTSecret string
+	JWTSecret        string
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	PasswordResetTTL time.Duration
+	FrontendURL      string
+	MailDriver       string
+	MailFrom         string
+	MailDir          string
+
+	// TrustedProxies are the proxies whose X-Forwarded-For header is believed
+	// when resolving the client IP; requests from other peers use their own address.
+	TrustedProxies []string
+
+	APIURL                     string
+	EmailVerificationTTL       time.Duration
+	VerificationResendInterval time.Duration
+	RequireVerifiedEmail       bool
+
+	LoginAttemptStore       string
+	LoginFreeAttempts       int
+	LoginBaseDelay          time.Duration
+	LoginMaxDelay           time.Duration
+	LoginMaxAccountFailures int
+	LoginMaxIPFailures      int
+	LoginLockoutDuration    time.Duration
+
+	MFAIssuer        string
+	MFAChallengeTTL  time.Duration
+	MFARequiredRoles []string
+
+	ImpersonationTTL time.Duration
+
+	OIDCProviderName string
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OAuthStateTTL    time.Duration
+
+	SKUPattern string
+	// PriceBuckets are the price ranges product facets count, e.g. "25-50" or "200-".
+	PriceBuckets []string
+
+	StorageDriver      string
+	StorageDir         string
+	StorageBaseURL     string
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	S3AccessKeyID      string
+	S3SecretAccessKey  string
+	S3PublicURL        string
+	ImageMaxBytes      int
+	ImageThumbnailSize int
 }
 
 // InitDB initializes the PostgreSQL connection
@@ -273,6 +330,133 @@ func LoadConfig() Config {
 	}
 
 	return Config{
-		JWTSecret: jwtSecret,
+		JWTSecret:        jwtSecret,
+		AccessTokenTTL:   getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:  getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		PasswordResetTTL: getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		FrontendURL:      getEnv("FRONTEND_URL", "http://localhost:3000"),
+		MailDriver:       getEnv("MAIL_DRIVER", "log"), // log | file
+		MailFrom:         getEnv("MAIL_FROM", "no-reply@clothes-shop.local"),
+		MailDir:          getEnv("MAIL_DIR", "tmp/mail"),
+
+		TrustedProxies: getListEnv("TRUSTED_PROXIES", nil),
+
+		APIURL:                     getEnv("API_URL", "http://localhost:8080"),
+		EmailVerificationTTL:       getDurationEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		VerificationResendInterval: getDurationEnv("VERIFICATION_RESEND_INTERVAL", time.Minute),
+		RequireVerifiedEmail:       getBoolEnv("REQUIRE_VERIFIED_EMAIL", false),
+
+		LoginAttemptStore:       getEnv("LOGIN_ATTEMPT_STORE", "postgres"), // postgres | memory
+		LoginFreeAttempts:       getIntEnv("LOGIN_FREE_ATTEMPTS", 3),
+		LoginBaseDelay:          getDurationEnv("LOGIN_BASE_DELAY", time.Second),
+		LoginMaxDelay:           getDurationEnv("LOGIN_MAX_DELAY", 30*time.Second),
+		LoginMaxAccountFailures: getIntEnv("LOGIN_MAX_ACCOUNT_FAILURES", 10),
+		LoginMaxIPFailures:      getIntEnv("LOGIN_MAX_IP_FAILURES", 50),
+		LoginLockoutDuration:    getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+
+		MFAIssuer:        getEnv("MFA_ISSUER", "Clothes Shop"),
+		MFAChallengeTTL:  getDurationEnv("MFA_CHALLENGE_TTL", 5*time.Minute),
+		MFARequiredRoles: getListEnv("MFA_REQUIRED_ROLES", []string{"admin"}),
+
+		ImpersonationTTL: getDurationEnv("IMPERSONATION_TTL", 15*time.Minute),
+
+		OIDCProviderName: getEnv("OIDC_PROVIDER_NAME", "oidc"),
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""), // social login is disabled when empty
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""), // defaults to API_URL/auth/oauth/<name>/callback
+		OIDCScopes:       getListEnv("OIDC_SCOPES", []string{"openid", "email", "profile"}),
+		OAuthStateTTL:    getDurationEnv("OAUTH_STATE_TTL", 10*time.Minute),
+
+		SKUPattern:   getEnv("SKU_PATTERN", "{product}-{color}-{size}"),
+		PriceBuckets: getListEnv("PRICE_BUCKETS", []string{"0-25", "25-50", "50-100", "100-200", "200-"}),
+
+		StorageDriver:      getEnv("STORAGE_DRIVER", "local"), // local | s3
+		StorageDir:         getEnv("STORAGE_DIR", "tmp/uploads"),
+		StorageBaseURL:     getEnv("STORAGE_BASE_URL", getEnv("API_URL", "http://localhost:8080")+"/uploads"),
+		S3Endpoint:         getEnv("S3_ENDPOINT", ""),
+		S3Region:           getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:           getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:      getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:  getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PublicURL:        getEnv("S3_PUBLIC_URL", ""), // defaults to S3_ENDPOINT/S3_BUCKET
+		ImageMaxBytes:      getIntEnv("IMAGE_MAX_BYTES", 5<<20),
+		ImageThumbnailSize: getIntEnv("IMAGE_THUMBNAIL_SIZE", 400),
 	}
 }
+
+// getListEnv parses a comma-separated list, falling back to def when unset.
+// Set the variable to "none" for an empty list.
+func getListEnv(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	if value == "none" {
+		return nil
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnv returns the value of key, or def when it is unset.
+func getEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getIntEnv parses a non-negative integer, falling back to def.
+func getIntEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using default %d", key, value, def)
+		return def
+	}
+
+	return n
+}
+
+// getBoolEnv parses a boolean such as "true" or "0", falling back to def.
+func getBoolEnv(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %t", key, value, def)
+		return def
+	}
+
+	return b
+}
+
+// getDurationEnv parses a duration such as "15m" or "720h", falling back to def.
+func getDurationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using default %s", key, value, def)
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,71 @@
+// Package dto holds the response shapes the API exposes to clients. Models are
+// never serialized directly when they carry credentials or other secrets.
+package dto
+
+import (
+	"clothes-shop-api/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserResponse is the public representation of a user. It deliberately has no
+// password, TOTP secret or other credential fields.
+type UserResponse struct {
+	ID               uuid.UUID  `json:"id"`
+	Email            string     `json:"email"`
+	Role             string     `json:"role"`
+	FullName         string     `json:"full_name"`
+	Phone            string     `json:"phone"`
+	DateOfBirth      *string    `json:"date_of_birth" example:"1995-08-21"`
+	PreferredSize    string     `json:"preferred_size"`
+	EmailVerified    bool       `json:"email_verified"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	IsActive         bool       `json:"is_active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func NewUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:               user.ID,
+		Email:            user.Email,
+		Role:             user.Role,
+		FullName:         user.FullName,
+		Phone:            user.Phone,
+		DateOfBirth:      user.DateOfBirth,
+		PreferredSize:    user.PreferredSize,
+		EmailVerified:    user.EmailVerifiedAt != nil,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		TwoFactorEnabled: user.TOTPEnabledAt != nil,
+		IsActive:         user.IsActive,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
+
+// AdminUserResponse adds the audit and soft-delete fields shown to admins.
+type AdminUserResponse struct {
+	UserResponse
+	IsDeleted bool       `json:"is_deleted"`
+	CreatedBy *uuid.UUID `json:"created_by"`
+	UpdatedBy *uuid.UUID `json:"updated_by"`
+}
+
+func NewAdminUserResponse(user *models.User) AdminUserResponse {
+	return AdminUserResponse{
+		UserResponse: NewUserResponse(user),
+		IsDeleted:    user.IsDeleted,
+		CreatedBy:    user.CreatedBy,
+		UpdatedBy:    user.UpdatedBy,
+	}
+}
+
+func NewAdminUserResponses(users []models.User) []AdminUserResponse {
+	responses := make([]AdminUserResponse, 0, len(users))
+	for i := range users {
+		responses = append(responses, NewAdminUserResponse(&users[i]))
+	}
+	return responses
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/auth"
+	"clothes-shop-api/internal/models"
+	"clothes-shop-api/internal/repositories"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyRepo *repositories.APIKeyRepository
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name" binding:"required,max=100"`
+	Scopes     []string   `json:"scopes" binding:"required,min=1" example:"catalog:write"`
+	ExpiresAt  *time.Time `json:"expires_at" example:"2026-12-31T23:59:59Z"`
+	AllowedIPs []string   `json:"allowed_ips" example:"10.0.0.0/24"`
+}
+
+// CreateAPIKeyResponse is the only response that contains the plain API key.
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+func NewAPIKeyHandler(apiKeyRepo *repositories.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyRepo: apiKeyRepo}
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Issue an API key for server-to-server integrations. Send it in the X-API-Key header. The key is returned only once. Scopes can be catalog:write and orders:manage. Admin only.
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param request body CreateAPIKeyRequest true "API key data"
+// @Success 201 {object} CreateAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !auth.IsValidAPIKeyScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope})
+			return
+		}
+	}
+
+	for _, rule := range req.AllowedIPs {
+		if !auth.IsValidIPRule(rule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address or CIDR range: " + rule})
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		// expires_at is stored without a time zone
+		expiresAt := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expiresAt
+	}
+
+	if req.AllowedIPs == nil {
+		req.AllowedIPs = []string{}
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	apiKey, err := h.apiKeyRepo.CreateAPIKey(c.Request.Context(), models.APIKey{
+		Name:       req.Name,
+		KeyPrefix:  prefix,
+		KeyHash:    hash,
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+	}, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		Key:    key,
+		APIKey: *apiKey,
+	})
+}
+
+// GetAllAPIKeys godoc
+// @Summary List API keys
+// @Description Retrieve all API keys that have not been revoked, newest first. Keys are identified by their prefix. Admin only.
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} ListResponse[models.APIKey]
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/api-keys [get]
+func (h *APIKeyHandler) GetAllAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyRepo.GetAllAPIKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(keys))
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Soft delete an API key so it can no longer be used. Admin only.
+// @Tags admin
+// @Produce  json
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/api-keys/{id}/soft-delete [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	key, err := h.apiKeyRepo.SoftDelete(c.Request.Context(), c.Param("id"), currentUserID(c))
+	if err != nil {
+		if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
@@ -1,24 +1,44 @@
 package handlers
 
 import (
+	"clothes-shop-api/internal/auth"
+	"clothes-shop-api/internal/config"
+	"clothes-shop-api/internal/dto"
+	"clothes-shop-api/internal/mailer"
+	"clothes-shop-api/internal/middleware"
 	"clothes-shop-api/internal/models"
+	"clothes-shop-api/internal/oauth"
 	"clothes-shop-api/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-	userRepo  *repositories.UserRepository
-	jwtSecret string
+	userRepo          *repositories.UserRepository
+	refreshTokenRepo  *repositories.RefreshTokenRepository
+	passwordResetRepo *repositories.PasswordResetRepository
+	verificationRepo  *repositories.EmailVerificationRepository
+	securityEventRepo *repositories.SecurityEventRepository
+	mfaRepo           *repositories.MFARepository
+	oauthRepo         *repositories.OAuthRepository
+	oauthProviders    map[string]oauth.Provider
+	loginGuard        *auth.LoginGuard
+	mailer            mailer.Sender
+	cfg               config.Config
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role"`
 }
 
 type LoginRequest struct {
@@ -26,21 +46,67 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	TokenResponse
+	User dto.UserResponse `json:"user"`
+}
+
+// MFAChallengeResponse is returned by Login instead of tokens when the account
+// has two-factor authentication enabled.
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int64  `json:"expires_in"`
 }
 
-func NewAuthHandler(userRepo *repositories.UserRepository, jwtSecret string) *AuthHandler {
+func NewAuthHandler(userRepo *repositories.UserRepository, refreshTokenRepo *repositories.RefreshTokenRepository, passwordResetRepo *repositories.PasswordResetRepository, verificationRepo *repositories.EmailVerificationRepository, securityEventRepo *repositories.SecurityEventRepository, mfaRepo *repositories.MFARepository, oauthRepo *repositories.OAuthRepository, oauthProviders []oauth.Provider, loginGuard *auth.LoginGuard, mail mailer.Sender, cfg config.Config) *AuthHandler {
+	providers := make(map[string]oauth.Provider, len(oauthProviders))
+	for _, p := range oauthProviders {
+		providers[p.Name()] = p
+	}
+
 	return &AuthHandler{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:          userRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		passwordResetRepo: passwordResetRepo,
+		verificationRepo:  verificationRepo,
+		securityEventRepo: securityEventRepo,
+		mfaRepo:           mfaRepo,
+		oauthRepo:         oauthRepo,
+		oauthProviders:    providers,
+		loginGuard:        loginGuard,
+		mailer:            mail,
+		cfg:               cfg,
 	}
 }
 
 // Register godoc
 // @Summary Register a new user
-// @Description Create a new user account
+// @Description Create a new customer account and email a verification link
 // @Tags auth
 // @Accept  json
 // @Produce  json
@@ -55,31 +121,32 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	if req.Role == "" {
-		req.Role = "customer"
-	}
-
-	user, err := h.userRepo.CreateUser(c.Request.Context(), req.Email, req.Password, req.Role)
+	// Self-registration always creates a customer; roles are changed by admins only
+	user, err := h.userRepo.CreateUser(c.Request.Context(), req.Email, req.Password, auth.RoleCustomer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	token, err := h.generateToken(user.ID.String(), user.Email, user.Role)
+	if err := h.sendVerificationEmail(c, user.ID.String(), user.Email); err != nil {
+		log.Println("Register: failed to send verification email:", err)
+	}
+
+	tokens, err := h.startSession(c, user, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  *user,
+		TokenResponse: *tokens,
+		User:          dto.NewUserResponse(user),
 	})
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return JWT token. When two-factor authentication is enabled, an MFAChallengeResponse is returned instead and the login is completed with POST /auth/login/2fa. Repeated failures are delayed and eventually lock the account or client IP temporarily.
 // @Tags auth
 // @Accept  json
 // @Produce  json
@@ -87,6 +154,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} AuthResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -95,38 +165,573 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userRepo.GetUserByEmail(c.Request.Context(), req.Email)
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	check, err := h.loginGuard.Check(ctx, req.Email, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		return
+	}
+	if check.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(check.RetryAfter.Seconds())+1))
+		if check.Locked {
+			c.JSON(http.StatusLocked, gin.H{"error": "Too many failed login attempts, please try again later"})
+			return
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before trying to login again"})
+		return
+	}
+
+	user, err := h.userRepo.GetUserByEmail(ctx, req.Email)
 	if err != nil {
+		if !errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+			return
+		}
+		// Unknown emails are counted too, so lockouts do not reveal which accounts exist
+		h.recordLoginFailure(c, nil, req.Email, ip)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	if !h.userRepo.CheckPassword(user.Password, req.Password) {
+		h.recordLoginFailure(c, user, req.Email, ip)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, err := h.generateToken(user.ID.String(), user.Email, user.Role)
+	if !user.IsActive || user.IsDeleted {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been deactivated"})
+		return
+	}
+
+	// The failure counter is reset once the second factor is verified too
+	if user.TOTPEnabledAt != nil {
+		challenge, err := h.mfaChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	if err := h.loginGuard.RecordSuccess(ctx, req.Email); err != nil {
+		log.Println("Login: failed to reset login attempts:", err)
+	}
+
+	tokens, err := h.startSession(c, user, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  *user,
+		TokenResponse: *tokens,
+		User:          dto.NewUserResponse(user),
+	})
+}
+
+// Refresh godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a rotated refresh token. Presenting a refresh token that was already used revokes the whole session.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	current, err := h.refreshTokenRepo.GetRefreshTokenByHash(ctx, auth.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	// A used or revoked token being presented again means it was leaked:
+	// revoke the whole session so neither party can keep using it.
+	if current.UsedAt != nil || current.RevokedAt != nil {
+		_ = h.refreshTokenRepo.RevokeFamily(ctx, current.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+		return
+	}
+
+	if time.Now().UTC().After(current.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired"})
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(ctx, current.UserID.String())
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	if !user.IsActive || user.IsDeleted {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This account has been deactivated"})
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	_, err = h.refreshTokenRepo.RotateRefreshToken(ctx, current, refreshHash, time.Now().UTC().Add(h.cfg.RefreshTokenTTL), c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenUsed) {
+			_ = h.refreshTokenRepo.RevokeFamily(ctx, current.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	accessToken, err := h.generateToken(user, current.MFA)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(h.cfg.AccessTokenTTL.Seconds()),
+	})
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the session that the given refresh token belongs to
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	current, err := h.refreshTokenRepo.GetRefreshTokenByHash(ctx, auth.HashToken(req.RefreshToken))
+	if err != nil && !errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	// Unknown tokens are treated as already logged out
+	if current != nil {
+		if err := h.refreshTokenRepo.RevokeFamily(ctx, current.FamilyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll godoc
+// @Summary Logout from all sessions
+// @Description Revoke every refresh token of the authenticated user. Access tokens already issued stay valid until they expire.
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	if err := h.refreshTokenRepo.RevokeAllForUser(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset token. The response is the same whether or not the email is registered.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If the email is registered, a password reset link has been sent"}
+	ctx := c.Request.Context()
+
+	user, err := h.userRepo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		// Do not reveal whether the account exists
+		if !errors.Is(err, repositories.ErrUserNotFound) {
+			log.Println("Forgot password: failed to load user:", err)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if !user.IsActive || user.IsDeleted {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	err = sendPasswordResetEmail(ctx, h.passwordResetRepo, h.mailer, h.cfg, user,
+		"We received a request to reset your password.", "If you did not request a reset, you can ignore this email.")
+	if err != nil {
+		log.Println("Forgot password: failed to send reset email:", err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password using a reset token. The token can be used once, and all existing sessions are revoked.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	userID, err := h.passwordResetRepo.ResetPassword(ctx, auth.HashToken(req.Token), req.NewPassword)
+	if err != nil {
+		if errors.Is(err, repositories.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := h.refreshTokenRepo.RevokeAllForUser(ctx, userID.String()); err != nil {
+		log.Println("Reset password: failed to revoke sessions:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the authenticated user's password. Other sessions are revoked and a new session is returned.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/change-password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	if !h.userRepo.CheckPassword(user.Password, req.CurrentPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if err := h.userRepo.UpdatePassword(ctx, userID, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	if err := h.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke existing sessions"})
+		return
+	}
+
+	tokens, err := h.startSession(c, user, c.GetBool(middleware.ContextMFA))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the email address using the token from the verification email. Tokens sent for an email change switch the account to the new address.
+// @Tags auth
+// @Produce  json
+// @Param token query string true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify-email [get]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	_, err := h.verificationRepo.VerifyEmail(c.Request.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		if errors.Is(err, repositories.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This email is already registered to another account"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification email to the authenticated user. Requests are throttled per user.
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	lastSentAt, err := h.verificationRepo.GetLastTokenCreatedAt(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend verification email"})
+		return
+	}
+	if lastSentAt != nil {
+		if wait := time.Until(lastSentAt.Add(h.cfg.VerificationResendInterval)); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another verification email"})
+			return
+		}
+	}
+
+	if err := h.sendVerificationEmail(c, userID, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// sendVerificationEmail issues a verification token for email and mails the link to it.
+func (h *AuthHandler) sendVerificationEmail(c *gin.Context, userID, email string) error {
+	return sendVerificationEmail(c.Request.Context(), h.verificationRepo, h.mailer, h.cfg, userID, email,
+		"Welcome to Clothes Shop!\n\nPlease confirm your email address by opening this link:")
+}
+
+// sendVerificationEmail issues a token that sets email as the user's verified
+// address and mails the verification link, preceded by intro, to that address.
+func sendVerificationEmail(ctx context.Context, verificationRepo *repositories.EmailVerificationRepository, mail mailer.Sender, cfg config.Config, userID, email, intro string) error {
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := verificationRepo.CreateVerificationToken(ctx, userID, email, tokenHash, time.Now().UTC().Add(cfg.EmailVerificationTTL)); err != nil {
+		return err
+	}
+
+	verifyURL := cfg.APIURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return mail.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body:    fmt.Sprintf("%s\n%s\n\nThe link expires in %s.", intro, verifyURL, cfg.EmailVerificationTTL),
+	})
+}
+
+// sendPasswordResetEmail issues a single-use reset token for user and mails the
+// reset link, wrapped in intro and outro, to the account address.
+func sendPasswordResetEmail(ctx context.Context, passwordResetRepo *repositories.PasswordResetRepository, mail mailer.Sender, cfg config.Config, user *models.User, intro, outro string) error {
+	token, tokenHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := passwordResetRepo.CreateResetToken(ctx, user.ID.String(), tokenHash, time.Now().UTC().Add(cfg.PasswordResetTTL)); err != nil {
+		return err
+	}
+
+	resetURL := cfg.FrontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	return mail.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("%s\n\nOpen this link to choose a new password:\n%s\n\nOr use this token: %s\n\nThe link expires in %s. %s",
+			intro, resetURL, token, cfg.PasswordResetTTL, outro),
 	})
 }
 
-func (h *AuthHandler) generateToken(userID, email, role string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"email":   email,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hours
-		"iat":     time.Now().Unix(),
+// recordLoginFailure counts a failed login and records any lockout it triggers.
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, user *models.User, email, ip string) {
+	ctx := c.Request.Context()
+
+	lockouts, err := h.loginGuard.RecordFailure(ctx, email, ip)
+	if err != nil {
+		log.Println("Login: failed to record login failure:", err)
+		return
+	}
+
+	for _, lockout := range lockouts {
+		event := models.SecurityEvent{
+			EventType: lockout.EventType,
+			Email:     email,
+			IPAddress: ip,
+			Details:   fmt.Sprintf("%d consecutive failed logins, locked until %s", lockout.Failures, lockout.LockedUntil.Format(time.RFC3339)),
+		}
+		if user != nil {
+			event.UserID = &user.ID
+		}
+		if err := h.securityEventRepo.CreateEvent(ctx, event); err != nil {
+			log.Println("Login: failed to record security event:", err)
+		}
+	}
+}
+
+// startSession issues an access token and the first refresh token of a new session.
+// mfa records whether the user has passed a second factor in this session.
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, mfa bool) (*TokenResponse, error) {
+	accessToken, err := h.generateToken(user, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = h.refreshTokenRepo.CreateRefreshToken(c.Request.Context(), user.ID.String(), uuid.New(), refreshHash,
+		time.Now().UTC().Add(h.cfg.RefreshTokenTTL), c.Request.UserAgent(), c.ClientIP(), mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(h.cfg.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// mfaChallenge issues the token that completes a login with POST /auth/login/2fa.
+func (h *AuthHandler) mfaChallenge(user *models.User) (*MFAChallengeResponse, error) {
+	challenge, err := auth.GenerateToken(h.cfg.JWTSecret, auth.Claims{
+		UserID:   user.ID.String(),
+		Email:    user.Email,
+		Role:     user.Role,
+		TokenUse: auth.TokenUseMFAChallenge,
+	}, h.cfg.MFAChallengeTTL)
+	if err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.jwtSecret))
+	return &MFAChallengeResponse{
+		MFARequired:    true,
+		ChallengeToken: challenge,
+		ExpiresIn:      int64(h.cfg.MFAChallengeTTL.Seconds()),
+	}, nil
+}
+
+func (h *AuthHandler) generateToken(user *models.User, mfa bool) (string, error) {
+	claims := auth.Claims{
+		UserID:        user.ID.String(),
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		MFA:           mfa,
+		TokenUse:      auth.TokenUseAccess,
+	}
+	return auth.GenerateToken(h.cfg.JWTSecret, claims, h.cfg.AccessTokenTTL)
 }
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/repositories"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BrandHandler struct {
+	repo *repositories.BrandRepository
+}
+
+type BrandRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	LogoURL     string `json:"logo_url" binding:"omitempty,url" example:"https://cdn.example.com/brands/nike.png"`
+}
+
+func NewBrandHandler(repo *repositories.BrandRepository) *BrandHandler {
+	return &BrandHandler{repo: repo}
+}
+
+// GetAllBrands godoc
+// @Summary Get all brands
+// @Description Retrieve a list of all active brands
+// @Tags brands
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} ListResponse[models.Brand]
+// @Router /brands [get]
+func (h *BrandHandler) GetAllBrands(c *gin.Context) {
+	brands, err := h.repo.GetAllBrands(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(brands))
+}
+
+// GetBrand godoc
+// @Summary Get a brand
+// @Description Retrieve a brand by ID
+// @Tags brands
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Brand ID"
+// @Success 200 {object} models.Brand
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /brands/{id} [get]
+func (h *BrandHandler) GetBrand(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+		return
+	}
+
+	brand, err := h.repo.GetBrandByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "Failed to load brand")
+		return
+	}
+
+	c.JSON(http.StatusOK, brand)
+}
+
+// CreateBrand godoc
+// @Summary Create a brand
+// @Description Create a brand with a unique name
+// @Tags brands
+// @Accept  json
+// @Produce  json
+// @Param request body BrandRequest true "Brand data"
+// @Success 201 {object} models.Brand
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /brands [post]
+func (h *BrandHandler) CreateBrand(c *gin.Context) {
+	var req BrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	brand, err := h.repo.CreateBrand(c.Request.Context(), strings.TrimSpace(req.Name), req.Description, req.LogoURL, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to create brand")
+		return
+	}
+
+	c.JSON(http.StatusCreated, brand)
+}
+
+// UpdateBrand godoc
+// @Summary Update a brand
+// @Description Update the name, description and logo of a brand
+// @Tags brands
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Brand ID"
+// @Param request body BrandRequest true "Brand data"
+// @Success 200 {object} models.Brand
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /brands/{id} [put]
+func (h *BrandHandler) UpdateBrand(c *gin.Context) {
+	var req BrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+		return
+	}
+
+	brand, err := h.repo.UpdateBrand(c.Request.Context(), c.Param("id"), strings.TrimSpace(req.Name), req.Description, req.LogoURL, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to update brand")
+		return
+	}
+
+	c.JSON(http.StatusOK, brand)
+}
+
+// SoftDeleteBrand godoc
+// @Summary Soft delete a brand
+// @Description Mark a brand as deleted. Brands that products still use cannot be deleted.
+// @Tags brands
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Brand ID"
+// @Success 200 {object} models.Brand
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /brands/{id}/soft-delete [delete]
+func (h *BrandHandler) SoftDeleteBrand(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+		return
+	}
+
+	brand, err := h.repo.SoftDelete(c.Request.Context(), c.Param("id"), currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to delete brand")
+		return
+	}
+
+	c.JSON(http.StatusOK, brand)
+}
+
+// RestoreBrand godoc
+// @Summary Restore a brand
+// @Description Undo the soft delete of a brand
+// @Tags brands
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Brand ID"
+// @Success 200 {object} models.Brand
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /brands/{id}/restore [patch]
+func (h *BrandHandler) RestoreBrand(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+		return
+	}
+
+	brand, err := h.repo.Restore(c.Request.Context(), c.Param("id"), currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to restore brand")
+		return
+	}
+
+	c.JSON(http.StatusOK, brand)
+}
+
+// respondError maps brand repository errors to responses.
+func (h *BrandHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrBrandNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+	case errors.Is(err, repositories.ErrBrandNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "A brand with this name already exists"})
+	case errors.Is(err, repositories.ErrBrandInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "This brand is still used by products"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/repositories"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	repo *repositories.CategoryRepository
+}
+
+type CategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	// ParentID places the category under another one; omit it for a top-level category.
+	ParentID *string `json:"parent_id" binding:"omitempty,uuid"`
+}
+
+func NewCategoryHandler(repo *repositories.CategoryRepository) *CategoryHandler {
+	return &CategoryHandler{repo: repo}
+}
+
+// GetAllCategories godoc
+// @Summary Get all categories
+// @Description Retrieve a list of all active categories
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} ListResponse[models.Category]
+// @Router /categories [get]
+func (h *CategoryHandler) GetAllCategories(c *gin.Context) {
+	categories, err := h.repo.GetAllCategories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(categories))
+}
+
+// GetCategoryTree godoc
+// @Summary Get the category tree
+// @Description Retrieve all active categories nested under their parent categories
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} ListResponse[models.Category]
+// @Failure 500 {object} map[string]string
+// @Router /categories/tree [get]
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.repo.GetCategoryTree(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(tree))
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Retrieve a category by ID
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /categories/{id} [get]
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	category, err := h.repo.GetCategoryByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "Failed to load category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Create a category with a unique name, optionally under a parent category
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param request body CategoryRequest true "Category data"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /categories [post]
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.repo.CreateCategory(c.Request.Context(), strings.TrimSpace(req.Name), req.Description, req.ParentID, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to create category")
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Update the name, description and parent of a category. A category cannot be moved under itself or one of its subcategories.
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Param request body CategoryRequest true "Category data"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /categories/{id} [put]
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	category, err := h.repo.UpdateCategory(c.Request.Context(), c.Param("id"), strings.TrimSpace(req.Name), req.Description, req.ParentID, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to update category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// SoftDeleteCategory godoc
+// @Summary Soft delete a category
+// @Description Mark a category as deleted. Categories that products or subcategories still use cannot be deleted.
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /categories/{id}/soft-delete [delete]
+func (h *CategoryHandler) SoftDeleteCategory(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	category, err := h.repo.SoftDelete(c.Request.Context(), c.Param("id"), currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to delete category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// RestoreCategory godoc
+// @Summary Restore a category
+// @Description Undo the soft delete of a category. Its parent category must be restored first.
+// @Tags categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /categories/{id}/restore [patch]
+func (h *CategoryHandler) RestoreCategory(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	category, err := h.repo.Restore(c.Request.Context(), c.Param("id"), currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to restore category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// respondError maps category repository errors to responses.
+func (h *CategoryHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, repositories.ErrCategoryNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "A category with this name already exists"})
+	case errors.Is(err, repositories.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "This category is still used by products"})
+	case errors.Is(err, repositories.ErrCategoryHasChildren):
+		c.JSON(http.StatusConflict, gin.H{"error": "This category still has subcategories"})
+	case errors.Is(err, repositories.ErrParentCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+	case errors.Is(err, repositories.ErrParentCategoryDeleted):
+		c.JSON(http.StatusConflict, gin.H{"error": "Restore the parent category first"})
+	case errors.Is(err, repositories.ErrCategoryCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A category cannot be moved under itself or one of its subcategories"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
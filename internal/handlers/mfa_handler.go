@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/auth"
+	"clothes-shop-api/internal/dto"
+	"clothes-shop-api/internal/middleware"
+	"clothes-shop-api/internal/models"
+	"clothes-shop-api/internal/repositories"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at a time.
+const recoveryCodeCount = 10
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TOTPConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	TokenResponse
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type LoginMFARequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// SetupTOTP godoc
+// @Summary Start two-factor enrollment
+// @Description Generate a new TOTP secret for the authenticated user. Two-factor authentication is enabled only after the secret is confirmed with a code.
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} TOTPSetupResponse
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandler) SetupTOTP(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := h.mfaRepo.SetPendingTOTPSecret(c.Request.Context(), user.ID.String(), secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(h.cfg.MFAIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with a code from the authenticator app. Returns recovery codes, shown only once, and a new session authenticated with the second factor.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body TOTPCodeRequest true "Authenticator code"
+// @Success 200 {object} TOTPConfirmResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/2fa/confirm [post]
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor enrollment first"})
+		return
+	}
+
+	counter, valid := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.mfaRepo.EnableTOTP(ctx, user.ID.String(), counter, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	// Sessions started with only a password no longer satisfy the account's security level
+	if err := h.refreshTokenRepo.RevokeAllForUser(ctx, user.ID.String()); err != nil {
+		log.Println("Confirm 2FA: failed to revoke sessions:", err)
+	}
+
+	tokens, err := h.startSession(c, user, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TOTPConfirmResponse{
+		RecoveryCodes: codes,
+		TokenResponse: *tokens,
+	})
+}
+
+// DisableTOTP godoc
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication after re-checking the password and a current code or recovery code. Not allowed for roles that require two-factor authentication.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body DisableTOTPRequest true "Password and authenticator or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	for _, role := range h.cfg.MFARequiredRoles {
+		if role == user.Role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+			return
+		}
+	}
+
+	if !h.userRepo.CheckPassword(user.Password, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	valid, err := h.verifySecondFactor(ctx, user, req.Code, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := h.mfaRepo.DisableTOTP(ctx, user.ID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes after checking a current authenticator code. The new codes are shown only once.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body TOTPCodeRequest true "Authenticator code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabledAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	valid, err := h.verifySecondFactor(ctx, user, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := h.mfaRepo.ReplaceRecoveryCodes(ctx, user.ID.String(), hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// LoginMFA godoc
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token returned by /login and an authenticator code (or a recovery code) for access and refresh tokens
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body LoginMFARequest true "Challenge token and code"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/login/2fa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	claims, err := auth.ParseToken(h.cfg.JWTSecret, req.ChallengeToken, auth.TokenUseMFAChallenge)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token, please log in again"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	// Codes are guessed through the same counters as passwords
+	check, err := h.loginGuard.Check(ctx, claims.Email, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		return
+	}
+	if check.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(check.RetryAfter.Seconds())+1))
+		if check.Locked {
+			c.JSON(http.StatusLocked, gin.H{"error": "Too many failed login attempts, please try again later"})
+			return
+		}
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before trying to login again"})
+		return
+	}
+
+	user, err := h.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid challenge token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		return
+	}
+
+	if user.TOTPEnabledAt == nil || !user.IsActive || user.IsDeleted {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid challenge token"})
+		return
+	}
+
+	valid, err := h.verifySecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+	if !valid {
+		h.recordLoginFailure(c, user, user.Email, ip)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := h.loginGuard.RecordSuccess(ctx, user.Email); err != nil {
+		log.Println("Login 2FA: failed to reset login attempts:", err)
+	}
+
+	tokens, err := h.startSession(c, user, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		TokenResponse: *tokens,
+		User:          dto.NewUserResponse(user),
+	})
+}
+
+// currentUser loads the authenticated user, writing an error response on failure.
+func (h *AuthHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// verifySecondFactor accepts either a current authenticator code or an unused
+// recovery code. Accepted codes cannot be used again.
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		if counter, valid := auth.ValidateTOTP(user.TOTPSecret, code, time.Now()); valid {
+			err := h.mfaRepo.UseTOTPCounter(ctx, user.ID.String(), counter)
+			if errors.Is(err, repositories.ErrTOTPCodeReused) {
+				return false, nil
+			}
+			return err == nil, err
+		}
+	}
+
+	if recoveryCode != "" {
+		return h.mfaRepo.UseRecoveryCode(ctx, user.ID.String(), auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode)))
+	}
+
+	return false, nil
+}
+
+// generateRecoveryCodes returns new recovery codes and the hashes to store for them.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashToken(code)
+	}
+
+	return codes, hashes, nil
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/auth"
+	"clothes-shop-api/internal/dto"
+	"clothes-shop-api/internal/models"
+	"clothes-shop-api/internal/oauth"
+	"clothes-shop-api/internal/repositories"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OAuthLogin godoc
+// @Summary Start a social login
+// @Description Redirect to the external identity provider using the authorization-code flow with PKCE. Pass redirect=false to receive the authorization URL as JSON instead.
+// @Tags auth
+// @Produce  json
+// @Param provider path string true "Provider name"
+// @Param redirect query bool false "Redirect to the provider (default true)"
+// @Success 200 {object} OAuthLoginResponse
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /auth/oauth/{provider}/login [get]
+func (h *AuthHandler) OAuthLogin(c *gin.Context) {
+	provider, ok := h.oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	state, stateHash, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := oauth.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier, err := oauth.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oauth.CodeChallenge(verifier))
+	if err != nil {
+		log.Println("OAuth login: provider discovery failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login provider is unavailable"})
+		return
+	}
+
+	err = h.oauthRepo.CreateLoginState(ctx, models.OAuthLoginState{
+		StateHash:    stateHash,
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(h.cfg.OAuthStateTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	if c.Query("redirect") == "false" {
+		c.JSON(http.StatusOK, OAuthLoginResponse{AuthorizationURL: authURL})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback godoc
+// @Summary Complete a social login
+// @Description Exchange the authorization code returned by the identity provider. The external account is linked to the user with the same verified email, or a new customer account is created. When an account with the email exists but has not verified it, the login is refused with 409. When two-factor authentication is enabled, an MFAChallengeResponse is returned instead.
+// @Tags auth
+// @Produce  json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login request"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /auth/oauth/{provider}/callback [get]
+func (h *AuthHandler) OAuthCallback(c *gin.Context) {
+	provider, ok := h.oauthProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown login provider"})
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login was not completed: " + providerErr})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	loginState, err := h.oauthRepo.ConsumeLoginState(ctx, auth.HashToken(state), provider.Name())
+	if err != nil {
+		if errors.Is(err, repositories.ErrOAuthStateInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state, please start again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		return
+	}
+
+	identity, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Println("OAuth callback: code exchange failed:", err)
+		if errors.Is(err, oauth.ErrInvalidIDToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The login provider returned an invalid identity"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Login provider is unavailable"})
+		return
+	}
+
+	user, status, msg := h.resolveOAuthUser(c, provider.Name(), identity)
+	if user == nil {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if !user.IsActive || user.IsDeleted {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been deactivated"})
+		return
+	}
+
+	if user.TOTPEnabledAt != nil {
+		challenge, err := h.mfaChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	tokens, err := h.startSession(c, user, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		TokenResponse: *tokens,
+		User:          dto.NewUserResponse(user),
+	})
+}
+
+// resolveOAuthUser finds the user linked to identity, links it to the account with
+// the same verified email, or creates a new customer. On failure it returns a nil
+// user with the status and message to respond with.
+func (h *AuthHandler) resolveOAuthUser(c *gin.Context, provider string, identity *oauth.Identity) (*models.User, int, string) {
+	ctx := c.Request.Context()
+
+	userID, err := h.oauthRepo.GetUserIDByIdentity(ctx, provider, identity.Subject)
+	if err == nil {
+		user, err := h.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Failed to load user"
+		}
+		return user, 0, ""
+	}
+	if !errors.Is(err, repositories.ErrIdentityNotFound) {
+		return nil, http.StatusInternalServerError, "Failed to login"
+	}
+
+	// Accounts are only matched or created by an email the provider has verified
+	email := strings.TrimSpace(identity.Email)
+	if email == "" || !identity.EmailVerified {
+		return nil, http.StatusBadRequest, "The login provider did not return a verified email address"
+	}
+
+	user, err := h.userRepo.GetUserByEmail(ctx, email)
+	if err == nil {
+		// Whoever registered an unverified account may not own the email, and
+		// linking would let their password keep working on the victim's account
+		if user.EmailVerifiedAt == nil {
+			return nil, http.StatusConflict, "An account with this email exists but its email is not verified. Sign in with your password and verify your email first"
+		}
+		if err := h.oauthRepo.LinkIdentity(ctx, user.ID.String(), provider, identity.Subject, email); err != nil {
+			return nil, http.StatusInternalServerError, "Failed to link account"
+		}
+		return user, 0, ""
+	}
+	if !errors.Is(err, repositories.ErrUserNotFound) {
+		return nil, http.StatusInternalServerError, "Failed to login"
+	}
+
+	// The random password can be replaced through the forgot password flow
+	password, _, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to create account"
+	}
+
+	user, err = h.oauthRepo.CreateUserWithIdentity(ctx, email, identity.Name, auth.RoleCustomer, password, provider, identity.Subject)
+	if err != nil {
+		if errors.Is(err, repositories.ErrEmailTaken) {
+			return nil, http.StatusConflict, "This email is already registered"
+		}
+		return nil, http.StatusInternalServerError, "Failed to create account"
+	}
+
+	return user, 0, ""
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/repositories"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OptionTypeHandler struct {
+	repo *repositories.OptionTypeRepository
+}
+
+type OptionTypeRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"Inseam"`
+}
+
+type CategoryOptionTypesRequest struct {
+	// OptionTypeIDs lists the option types the category offers itself, in display order.
+	OptionTypeIDs []string `json:"option_type_ids" binding:"required,dive,uuid"`
+}
+
+func NewOptionTypeHandler(repo *repositories.OptionTypeRepository) *OptionTypeHandler {
+	return &OptionTypeHandler{repo: repo}
+}
+
+// GetAllOptionTypes godoc
+// @Summary Get all option types
+// @Description Retrieve the option types variants can differ by, such as Size, Color or Inseam
+// @Tags option-types
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} ListResponse[models.OptionType]
+// @Failure 500 {object} map[string]string
+// @Router /option-types [get]
+func (h *OptionTypeHandler) GetAllOptionTypes(c *gin.Context) {
+	optionTypes, err := h.repo.GetAllOptionTypes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load option types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(optionTypes))
+}
+
+// CreateOptionType godoc
+// @Summary Create an option type
+// @Description Create an option type with a unique name
+// @Tags option-types
+// @Accept  json
+// @Produce  json
+// @Param request body OptionTypeRequest true "Option type data"
+// @Success 201 {object} models.OptionType
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /option-types [post]
+func (h *OptionTypeHandler) CreateOptionType(c *gin.Context) {
+	var req OptionTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+
+	optionType, err := h.repo.CreateOptionType(c.Request.Context(), name, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to create option type")
+		return
+	}
+
+	c.JSON(http.StatusCreated, optionType)
+}
+
+// UpdateOptionType godoc
+// @Summary Rename an option type
+// @Description Rename an option type. Size and Color are built in and cannot be renamed.
+// @Tags option-types
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Option type ID"
+// @Param request body OptionTypeRequest true "Option type data"
+// @Success 200 {object} models.OptionType
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /option-types/{id} [put]
+func (h *OptionTypeHandler) UpdateOptionType(c *gin.Context) {
+	var req OptionTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Option type not found"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+
+	optionType, err := h.repo.UpdateOptionType(c.Request.Context(), c.Param("id"), name, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to update option type")
+		return
+	}
+
+	c.JSON(http.StatusOK, optionType)
+}
+
+// GetCategoryOptionTypes godoc
+// @Summary Get the option types of a category
+// @Description Retrieve the option types variants of products in a category can use, including those inherited from parent categories
+// @Tags option-types
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} ListResponse[models.OptionType]
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /categories/{id}/option-types [get]
+func (h *OptionTypeHandler) GetCategoryOptionTypes(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	optionTypes, err := h.repo.GetCategoryOptionTypes(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "Failed to load option types")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(optionTypes))
+}
+
+// SetCategoryOptionTypes godoc
+// @Summary Set the option types of a category
+// @Description Replace the option types a category offers itself. Subcategories inherit them, and inherited option types are not affected.
+// @Tags option-types
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Param request body CategoryOptionTypesRequest true "Option types"
+// @Success 200 {object} ListResponse[models.OptionType]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /categories/{id}/option-types [put]
+func (h *OptionTypeHandler) SetCategoryOptionTypes(c *gin.Context) {
+	var req CategoryOptionTypesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if err := h.repo.SetCategoryOptionTypes(c.Request.Context(), c.Param("id"), req.OptionTypeIDs); err != nil {
+		if errors.Is(err, repositories.ErrOptionTypeNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Option type not found"})
+			return
+		}
+		h.respondError(c, err, "Failed to update category option types")
+		return
+	}
+
+	optionTypes, err := h.repo.GetCategoryOptionTypes(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "Failed to load option types")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(optionTypes))
+}
+
+// respondError maps option type repository errors to responses.
+func (h *OptionTypeHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrOptionTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Option type not found"})
+	case errors.Is(err, repositories.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, repositories.ErrOptionTypeNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "An option type with this name already exists"})
+	case errors.Is(err, repositories.ErrOptionTypeBuiltIn):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Size and Color are built in and cannot be renamed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/repositories"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPageLimit caps the limit query parameter of paginated listings.
+const maxPageLimit = 100
+
+// ListResponse is one page of a paginated listing.
+type ListResponse[T any] struct {
+	Items []T `json:"items"`
+	// Total is the number of items on all pages.
+	Total int `json:"total"`
+	// Page is the page number, or 0 when the page was selected with a cursor.
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+	// NextCursor selects the following page when passed as cursor; it is null on the last page.
+	NextCursor *string `json:"next_cursor"`
+}
+
+// pagination reads the page, limit and cursor query parameters. A cursor takes
+// precedence over the page number. It responds with 400 and returns false for
+// an invalid cursor.
+func pagination(c *gin.Context, defaultLimit int) (repositories.Pagination, bool) {
+	p := repositories.Pagination{Page: 1, Limit: defaultLimit}
+
+	if page := c.Query("page"); page != "" {
+		if parsed, err := strconv.Atoi(page); err == nil && parsed > 0 {
+			p.Page = parsed
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if parsed, err := strconv.Atoi(limit); err == nil && parsed > 0 && parsed <= maxPageLimit {
+			p.Limit = parsed
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := repositories.ParseCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return repositories.Pagination{}, false
+		}
+		p.Page = 0
+		p.After = &after
+	}
+
+	return p, true
+}
+
+// newListResponse wraps the items of a page, which may have been converted
+// from page.Items, in a ListResponse.
+func newListResponse[T, R any](page repositories.Page[R], items []T, p repositories.Pagination) ListResponse[T] {
+	response := ListResponse[T]{Items: items, Total: page.Total, Page: p.Page, Limit: p.Limit}
+	if response.Items == nil {
+		response.Items = []T{}
+	}
+	if page.Next != nil {
+		next := page.Next.String()
+		response.NextCursor = &next
+	}
+	return response
+}
+
+// newCollectionResponse wraps a complete collection, such as all brands, in a
+// ListResponse holding a single page, so every listing has the same shape.
+func newCollectionResponse[T any](items []T) ListResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return ListResponse[T]{Items: items, Total: len(items), Page: 1, Limit: len(items)}
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"clothes-shop-api/internal/middleware"
+	"clothes-shop-api/internal/models"
 	"clothes-shop-api/internal/repositories"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type ProductHandler struct {
@@ -13,97 +18,349 @@ type ProductHandler struct {
 }
 
 type VariantRequest struct {
-	Size  string  `json:"size" binding:"required"`
-	Color string  `json:"color" binding:"required"`
-	Stock int     `json:"stock" binding:"required,min=0"`
-	Price float64 `json:"price" binding:"required,min=0"`
-	Image string  `json:"image"`
+	// ID matches an existing variant when updating a product; it is ignored on create.
+	ID string `json:"id" binding:"omitempty,uuid"`
+	// SKU is generated from the SKU pattern when empty.
+	SKU     string  `json:"sku" binding:"omitempty,max=64" example:"NIKE-SPORT-T-SHIRT-BLACK-M"`
+	Barcode string  `json:"barcode" example:"4006381333931"`
+	Size    string  `json:"size" binding:"required"`
+	Color   string  `json:"color" binding:"required"`
+	Stock   int     `json:"stock" binding:"required,min=0"`
+	Price   float64 `json:"price" binding:"required,min=0"`
+	Image   string  `json:"image"`
+	// Options holds values of option types other than size and color, such as
+	// {"Inseam": "32"}. The product's category must offer each option type.
+	Options map[string]string `json:"options"`
+}
+
+type UpdateVariantRequest struct {
+	SKU *string `json:"sku" binding:"omitempty,min=1,max=64"`
+	// Barcode is removed when set to an empty string.
+	Barcode *string  `json:"barcode"`
+	Size    *string  `json:"size" binding:"omitempty,min=1"`
+	Color   *string  `json:"color" binding:"omitempty,min=1"`
+	Stock   *int     `json:"stock" binding:"omitempty,min=0"`
+	Price   *float64 `json:"price" binding:"omitempty,min=0"`
+	Image   *string  `json:"image"`
+	// Options replaces all option values other than size and color; {} removes them.
+	Options map[string]string `json:"options"`
 }
 
 type CreateProductRequest struct {
-	Name         string           `json:"name" binding:"required"`
-	Description  string           `json:"description"`
-	CategoryName string           `json:"category_name" binding:"required"`
-	BrandName    string           `json:"brand_name"`
-	Variants     []VariantRequest `json:"variants" binding:"required,min=1"`
+	Name         string `json:"name" binding:"required"`
+	Slug         string `json:"slug" binding:"omitempty,max=200" example:"nike-sport-t-shirt"`
+	Description  string `json:"description"`
+	CategoryName string `json:"category_name" binding:"required"`
+	BrandName    string `json:"brand_name"`
+	// Attributes describe the product, such as {"Fabric": "Cotton", "Country of origin": "Portugal"}.
+	Attributes map[string]string `json:"attributes"`
+	Variants   []VariantRequest  `json:"variants" binding:"required,min=1"`
 }
 
 type UpdateProductRequest struct {
-	Name         string           `json:"name" binding:"required"`
-	Description  string           `json:"description"`
-	CategoryName string           `json:"category_name" binding:"required"`
-	BrandName    string           `json:"brand_name"`
-	Variants     []VariantRequest `json:"variants" binding:"required,min=1"`
+	Name         string `json:"name" binding:"required"`
+	Slug         string `json:"slug" binding:"omitempty,max=200" example:"nike-sport-t-shirt"`
+	Description  string `json:"description"`
+	CategoryName string `json:"category_name" binding:"required"`
+	BrandName    string `json:"brand_name"`
+	// Attributes replace the current attributes; they are kept when left out.
+	Attributes map[string]string `json:"attributes"`
+	Variants   []VariantRequest  `json:"variants" binding:"required,min=1"`
 }
 
+// Limits on product attributes and variant option values.
+const (
+	maxAttributeNameLength  = 100
+	maxAttributeValueLength = 500
+)
+
 func NewProductHandler(repo *repositories.ProductRepository) *ProductHandler {
 	return &ProductHandler{repo: repo}
 }
 
+// currentUserID returns the authenticated user's ID for the created_by/updated_by audit columns.
+// Requests made with an API key are attributed to the admin who created the key.
+func currentUserID(c *gin.Context) *string {
+	if userID, ok := middleware.GetUserID(c); ok {
+		return &userID
+	}
+	if ownerID := c.GetString(middleware.ContextAPIKeyOwnerID); ownerID != "" {
+		return &ownerID
+	}
+	return nil
+}
+
+// variantInputs converts variant requests for the repository. IDs are kept so
+// existing variants can be matched when updating a product.
+func variantInputs(reqs []VariantRequest) []repositories.VariantInput {
+	variants := make([]repositories.VariantInput, len(reqs))
+	for i, v := range reqs {
+		variants[i] = repositories.VariantInput{
+			SKU:     normalizeSKU(v.SKU),
+			Barcode: strings.TrimSpace(v.Barcode),
+			Size:    v.Size,
+			Color:   v.Color,
+			Stock:   v.Stock,
+			Price:   v.Price,
+			Image:   v.Image,
+			Options: v.Options,
+		}
+		if v.ID != "" {
+			id := v.ID
+			variants[i].ID = &id
+		}
+	}
+	return variants
+}
+
+// validateVariantInputs returns why a variant SKU, barcode or option value is
+// rejected, or "" when all of them are valid.
+func validateVariantInputs(variants []repositories.VariantInput) string {
+	for _, v := range variants {
+		if msg := variantCodeError(v.SKU, v.Barcode); msg != "" {
+			return msg
+		}
+		if msg := variantOptionsError(v.Options); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// variantOptionsError returns why variant option values are rejected, or "" when
+// they are valid. Size and color have their own fields.
+func variantOptionsError(options map[string]string) string {
+	for name := range options {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "size", "color":
+			return "set size and color with the size and color fields, not options"
+		}
+	}
+	return namedValuesError("option", options)
+}
+
+// namedValuesError returns why attributes or option values are rejected, or ""
+// when every name and value is set, short enough and names are unique
+// regardless of case.
+func namedValuesError(kind string, values map[string]string) string {
+	seen := make(map[string]bool, len(values))
+	for name, value := range values {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" || value == "" {
+			return kind + " names and values cannot be empty"
+		}
+		if len(name) > maxAttributeNameLength || len(value) > maxAttributeValueLength {
+			return kind + " names may be at most 100 characters and values at most 500"
+		}
+		if seen[strings.ToLower(name)] {
+			return kind + " names must be unique: " + name
+		}
+		seen[strings.ToLower(name)] = true
+	}
+	return ""
+}
+
+// variantCodeError returns why a normalized SKU or barcode is rejected, or "" when
+// each is valid or empty.
+func variantCodeError(sku, barcode string) string {
+	if sku != "" && !repositories.ValidSKU.MatchString(sku) {
+		return "sku may only contain letters, digits and single '.', '_' or '-' separators"
+	}
+	if barcode != "" && !repositories.ValidBarcode(barcode) {
+		return "barcode must be an EAN-8, UPC-A or EAN-13 code with a valid check digit"
+	}
+	return ""
+}
+
+// normalizeSKU upper-cases a SKU so lookups do not depend on how it was typed.
+func normalizeSKU(sku string) string {
+	return strings.ToUpper(strings.TrimSpace(sku))
+}
+
+// isUUID reports whether id is a valid UUID, so malformed IDs can be answered with 404.
+func isUUID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+// ProductListResponse is a page of products, with the facet counts under the
+// same filters when they were requested.
+type ProductListResponse struct {
+	ListResponse[models.Product]
+	Facets *models.ProductFacets `json:"facets,omitempty"`
+}
+
+// queryValues returns the non-empty values of a query parameter that may be repeated.
+func queryValues(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // GetAllProducts godoc
 // @Summary Get all products with pagination and filters
-// @Description Retrieve a list of products with optional pagination, price filter, category filter, brand filter, and search
+// @Description Retrieve a page of products with optional price filter, category filter, brand filter, and search.
+// @Description Order the products with sort: relevance, newest, price_asc, price_desc (by lowest price), name, best_selling or top_rated. Give several keys, comma-separated or repeated, to break ties with the next key, for example sort=price_asc,newest. Products with equal keys keep a fixed order by ID. Search results are sorted by relevance by default, other listings by newest.
+// @Description Select a page with page, or pass the next_cursor of a response as cursor to fetch the following page. Cursor pages stay fast however deep they are; they are only available for listings sorted by newest.
+// @Description Repeat category, brand, size, color and price_range to match products with any of the values, for example size=M&size=L.
+// @Description With facets=true the response also holds, for each facet, the number of products per value. The counts of a facet apply every filter except the ones on that facet itself, so other values stay selectable.
+// @Description Filter on attributes with attributes[name]=value, for example attributes[fabric]=cotton, and on variant options with options[name]=value, for example options[size]=M&options[inseam]=32. A product matches option filters when one of its variants has all the values. Names and values are not case-sensitive.
 // @Tags products
 // @Accept  json
 // @Produce  json
 // @Param page query int false "Page number (default 1)" default(1)
 // @Param limit query int false "Items per page (default 10)" default(10)
+// @Param cursor query string false "Cursor of the page to fetch, from next_cursor"
 // @Param min_price query number false "Minimum price filter"
 // @Param max_price query number false "Maximum price filter"
-// @Param category query string false "Category name filter"
-// @Param brand query string false "Brand name filter"
-// @Param search query string false "Product name search"
-// @Success 200 {array} models.Product
+// @Param category query []string false "Category ID or name filter" collectionFormat(multi)
+// @Param include_subcategories query bool false "Include products in subcategories of the category (default true)"
+// @Param brand query []string false "Brand ID or name filter" collectionFormat(multi)
+// @Param size query []string false "Size of a variant in stock" collectionFormat(multi)
+// @Param color query []string false "Color of a variant in stock" collectionFormat(multi)
+// @Param price_range query []string false "Price range of the lowest price, written as 25-50 or 200-" collectionFormat(multi)
+// @Param facets query bool false "Include facet counts"
+// @Param sort query []string false "Sort keys, in order of precedence" collectionFormat(csv)
+// @Param search query string false "Full-text search over name, brand, category and description. Supports quoted phrases, OR and -excluded words. Results are ordered by relevance unless sorted otherwise and include highlighted snippets."
+// @Success 200 {object} ProductListResponse
+// @Failure 400 {object} map[string]string
 // @Router /products [get]
 func (h *ProductHandler) GetAllProducts(c *gin.Context) {
-	page := 1
-	limit := 10
-
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
-			page = parsed
-		}
-	}
-
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
+	pagination, ok := pagination(c, 10)
+	if !ok {
+		return
 	}
 
-	var minPrice, maxPrice *float64
+	var filter repositories.ProductFilter
 	if mp := c.Query("min_price"); mp != "" {
 		if parsed, err := strconv.ParseFloat(mp, 64); err == nil {
-			minPrice = &parsed
+			filter.MinPrice = &parsed
 		}
 	}
 
 	if mp := c.Query("max_price"); mp != "" {
 		if parsed, err := strconv.ParseFloat(mp, 64); err == nil {
-			maxPrice = &parsed
+			filter.MaxPrice = &parsed
 		}
 	}
 
-	var categoryName, brandName, searchName *string
-	if cat := c.Query("category"); cat != "" {
-		categoryName = &cat
+	for _, value := range queryValues(c, "price_range") {
+		if priceRange, ok := repositories.ParsePriceRange(value); ok {
+			filter.PriceRanges = append(filter.PriceRanges, priceRange)
+		}
 	}
 
-	if brand := c.Query("brand"); brand != "" {
-		brandName = &brand
+	filter.Categories = queryValues(c, "category")
+
+	if include := c.Query("include_subcategories"); include != "" {
+		if parsed, err := strconv.ParseBool(include); err == nil {
+			filter.ExcludeSubcategories = !parsed
+		}
 	}
 
+	filter.Brands = queryValues(c, "brand")
+	filter.Sizes = queryValues(c, "size")
+	filter.Colors = queryValues(c, "color")
+
 	if search := c.Query("search"); search != "" {
-		searchName = &search
+		filter.Search = &search
+	}
+
+	if attributes := c.QueryMap("attributes"); len(attributes) > 0 {
+		filter.Attributes = attributes
+	}
+
+	if options := c.QueryMap("options"); len(options) > 0 {
+		filter.Options = options
+	}
+
+	sort, err := repositories.ParseProductSort(c.QueryArray("sort"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.repo.GetAllProducts(c.Request.Context(), pagination, filter, sort)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCursorNotSupported) || errors.Is(err, repositories.ErrRelevanceNeedsSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := ProductListResponse{ListResponse: newListResponse(page, page.Items, pagination)}
+
+	if withFacets, _ := strconv.ParseBool(c.Query("facets")); withFacets {
+		response.Facets, err = h.repo.GetProductFacets(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetProduct godoc
+// @Summary Get a product by ID
+// @Description Retrieve an active product with its variants, category and brand
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Success 200 {object} models.Product
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id} [get]
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	id := c.Param("id")
+	if !isUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	product, err := h.repo.GetProductByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	products, err := h.repo.GetAllProducts(c.Request.Context(), page, limit, minPrice, maxPrice, categoryName, brandName, searchName)
+	c.JSON(http.StatusOK, product)
+}
+
+// GetProductBySlug godoc
+// @Summary Get a product by slug
+// @Description Retrieve an active product by its URL slug, with its variants, category and brand
+// @Tags products
+// @Accept  json
+// @Produce  json
+// @Param slug path string true "Product slug"
+// @Success 200 {object} models.Product
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/slug/{slug} [get]
+func (h *ProductHandler) GetProductBySlug(c *gin.Context) {
+	product, err := h.repo.GetProductBySlug(c.Request.Context(), c.Param("slug"))
 	if err != nil {
+		if errors.Is(err, repositories.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusOK, product)
 }
 
 // CreateProduct godoc
@@ -115,7 +372,12 @@ func (h *ProductHandler) GetAllProducts(c *gin.Context) {
 // @Param request body CreateProductRequest true "Product creation data"
 // @Success 201 {object} models.Product
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /products [post]
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var req CreateProductRequest
@@ -124,72 +386,43 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Calculate min_price, max_price, total_stock from variants
-	minPrice := req.Variants[0].Price
-	maxPrice := req.Variants[0].Price
-	totalStock := req.Variants[0].Stock
-
-	for _, variant := range req.Variants {
-		if variant.Price < minPrice {
-			minPrice = variant.Price
-		}
-		if variant.Price > maxPrice {
-			maxPrice = variant.Price
-		}
-		totalStock += variant.Stock
-	}
-
-	product, err := h.repo.CreateProduct(c.Request.Context(), req.Name, req.Description, minPrice, maxPrice, totalStock, req.CategoryName, req.BrandName)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if req.Slug != "" && !repositories.ValidSlug.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug may only contain lowercase letters, digits and single hyphens"})
 		return
 	}
 
-	// Create variants
-	variants := make([]struct {
-		Size  string
-		Color string
-		Stock int
-		Price float64
-		Image string
-	}, len(req.Variants))
-
-	for i, v := range req.Variants {
-		variants[i] = struct {
-			Size  string
-			Color string
-			Stock int
-			Price float64
-			Image string
-		}{
-			Size:  v.Size,
-			Color: v.Color,
-			Stock: v.Stock,
-			Price: v.Price,
-			Image: v.Image,
-		}
+	if msg := namedValuesError("attribute", req.Attributes); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
 	}
 
-	err = h.repo.CreateProductVariants(c.Request.Context(), product.ID.String(), variants)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product variants"})
+	variants := variantInputs(req.Variants)
+	if msg := validateVariantInputs(variants); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
 
-	// Load the variants into the product response
-	productVariants, err := h.repo.GetProductVariants(c.Request.Context(), product.ID.String())
+	input := repositories.ProductInput{
+		Name:         req.Name,
+		Slug:         req.Slug,
+		Description:  req.Description,
+		CategoryName: req.CategoryName,
+		BrandName:    req.BrandName,
+		Attributes:   req.Attributes,
+		Variants:     variants,
+	}
+	product, err := h.repo.CreateProduct(c.Request.Context(), input, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load product variants"})
+		h.respondProductError(c, err, "Failed to create product")
 		return
 	}
-	product.Variants = productVariants
 
 	c.JSON(http.StatusCreated, product)
 }
 
 // UpdateProduct godoc
 // @Summary Update an existing product
-// @Description Update a product with the provided details
+// @Description Update a product with the provided details. Each variant updates the existing variant with the same id, or else the one with the same size, color and options, and is created when neither exists. Existing variants that are left out are soft-deleted. Attributes are kept when left out.
 // @Tags products
 // @Accept  json
 // @Produce  json
@@ -197,7 +430,13 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 // @Param request body UpdateProductRequest true "Product update data"
 // @Success 200 {object} models.Product
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /products/{id} [put]
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
@@ -207,103 +446,47 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	// Calculate min_price, max_price, total_stock from variants
-	minPrice := req.Variants[0].Price
-	maxPrice := req.Variants[0].Price
-	totalStock := req.Variants[0].Stock
-
-	for _, variant := range req.Variants {
-		if variant.Price < minPrice {
-			minPrice = variant.Price
-		}
-		if variant.Price > maxPrice {
-			maxPrice = variant.Price
-		}
-		totalStock += variant.Stock
-	}
-
-	product, err := h.repo.UpdateProduct(c.Request.Context(), id, req.Name, req.Description, minPrice, maxPrice, totalStock, req.CategoryName, req.BrandName, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
-		return
-	}
-
-	// Update variants
-	variants := make([]struct {
-		Size  string
-		Color string
-		Stock int
-		Price float64
-		Image string
-	}, len(req.Variants))
-
-	for i, v := range req.Variants {
-		variants[i] = struct {
-			Size  string
-			Color string
-			Stock int
-			Price float64
-			Image string
-		}{
-			Size:  v.Size,
-			Color: v.Color,
-			Stock: v.Stock,
-			Price: v.Price,
-			Image: v.Image,
-		}
+	if req.Slug != "" && !repositories.ValidSlug.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug may only contain lowercase letters, digits and single hyphens"})
+		return
 	}
 
-	err = h.repo.UpdateProductVariants(c.Request.Context(), id, variants)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product variants"})
+	if !isUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
 
-	// Load the variants into the product response
-	productVariants, err := h.repo.GetProductVariants(c.Request.Context(), product.ID.String())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load product variants"})
+	if msg := namedValuesError("attribute", req.Attributes); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
-	product.Variants = productVariants
-
-	c.JSON(http.StatusOK, product)
-}
 
-// GetAllCategories godoc
-// @Summary Get all categories
-// @Description Retrieve a list of all categories
-// @Tags categories
-// @Accept  json
-// @Produce  json
-// @Success 200 {array} models.Category
-// @Router /categories [get]
-func (h *ProductHandler) GetAllCategories(c *gin.Context) {
-	categories, err := h.repo.GetAllCategories(c.Request.Context())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	variants := variantInputs(req.Variants)
+	if msg := validateVariantInputs(variants); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
 
-	c.JSON(http.StatusOK, categories)
-}
-
-// GetAllBrands godoc
-// @Summary Get all brands
-// @Description Retrieve a list of all brands
-// @Tags brands
-// @Accept  json
-// @Produce  json
-// @Success 200 {array} models.Brand
-// @Router /brands [get]
-func (h *ProductHandler) GetAllBrands(c *gin.Context) {
-	brands, err := h.repo.GetAllBrands(c.Request.Context())
+	input := repositories.ProductInput{
+		Name:         req.Name,
+		Slug:         req.Slug,
+		Description:  req.Description,
+		CategoryName: req.CategoryName,
+		BrandName:    req.BrandName,
+		Attributes:   req.Attributes,
+		Variants:     variants,
+	}
+	product, err := h.repo.UpdateProduct(c.Request.Context(), id, input, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, repositories.ErrVariantNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A variant ID does not belong to this product"})
+			return
+		}
+		h.respondProductError(c, err, "Failed to update product")
 		return
 	}
 
-	c.JSON(http.StatusOK, brands)
+	c.JSON(http.StatusOK, product)
 }
 
 // ToggleActive godoc
@@ -314,14 +497,24 @@ func (h *ProductHandler) GetAllBrands(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Product ID"
 // @Success 200 {object} models.Product
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /products/{id}/toggle-active [patch]
 func (h *ProductHandler) ToggleActive(c *gin.Context) {
 	id := c.Param("id")
 
-	product, err := h.repo.ToggleActive(c.Request.Context(), id, nil)
+	if !isUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	product, err := h.repo.ToggleActive(c.Request.Context(), id, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondProductError(c, err, "Failed to toggle product active status")
 		return
 	}
 
@@ -336,20 +529,256 @@ func (h *ProductHandler) ToggleActive(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Product ID"
 // @Success 200 {object} models.Product
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /products/{id}/soft-delete [delete]
 func (h *ProductHandler) SoftDelete(c *gin.Context) {
 	id := c.Param("id")
 
-	product, err := h.repo.SoftDelete(c.Request.Context(), id, nil)
+	if !isUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	product, err := h.repo.SoftDelete(c.Request.Context(), id, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondProductError(c, err, "Failed to soft delete product")
 		return
 	}
 
 	c.JSON(http.StatusOK, product)
 }
 
+// CreateVariant godoc
+// @Summary Add a product variant
+// @Description Add a variant to an existing product
+// @Tags product-variants
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param request body VariantRequest true "Variant data"
+// @Success 201 {object} models.ProductVariant
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /products/{id}/variants [post]
+func (h *ProductHandler) CreateVariant(c *gin.Context) {
+	var req VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID := c.Param("id")
+	if !isUUID(productID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	input := variantInputs([]VariantRequest{req})[0]
+	if msg := validateVariantInputs([]repositories.VariantInput{input}); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	variant, err := h.repo.CreateVariant(c.Request.Context(), productID, input, currentUserID(c))
+	if err != nil {
+		h.respondVariantError(c, err, "Failed to create product variant")
+		return
+	}
+
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateVariant godoc
+// @Summary Update a product variant
+// @Description Change the given fields of a variant; fields that are left out keep their value
+// @Tags product-variants
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Product Variant ID"
+// @Param request body UpdateVariantRequest true "Variant fields to change"
+// @Success 200 {object} models.ProductVariant
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /products/{id}/variants/{variantId} [patch]
+func (h *ProductHandler) UpdateVariant(c *gin.Context) {
+	var req UpdateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID, variantID := c.Param("id"), c.Param("variantId")
+	if !isUUID(productID) || !isUUID(variantID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+		return
+	}
+
+	update := repositories.VariantUpdate{Size: req.Size, Color: req.Color, Stock: req.Stock, Price: req.Price, Image: req.Image, Options: req.Options}
+	var sku, barcode string
+	if req.SKU != nil {
+		if sku = normalizeSKU(*req.SKU); sku == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sku cannot be empty"})
+			return
+		}
+		update.SKU = &sku
+	}
+	if req.Barcode != nil {
+		barcode = strings.TrimSpace(*req.Barcode)
+		update.Barcode = &barcode
+	}
+	if msg := variantCodeError(sku, barcode); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := variantOptionsError(req.Options); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	variant, err := h.repo.UpdateVariant(c.Request.Context(), productID, variantID, update, currentUserID(c))
+	if err != nil {
+		h.respondVariantError(c, err, "Failed to update product variant")
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteVariant godoc
+// @Summary Delete a product variant
+// @Description Soft delete a variant of a product. The variant keeps its ID for carts and orders that reference it.
+// @Tags product-variants
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Product Variant ID"
+// @Success 200 {object} models.ProductVariant
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /products/{id}/variants/{variantId} [delete]
+func (h *ProductHandler) DeleteVariant(c *gin.Context) {
+	productID, variantID := c.Param("id"), c.Param("variantId")
+	if !isUUID(productID) || !isUUID(variantID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+		return
+	}
+
+	variant, err := h.repo.SoftDeleteProductVariant(c.Request.Context(), productID, variantID, currentUserID(c))
+	if err != nil {
+		h.respondVariantError(c, err, "Failed to delete product variant")
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// respondProductError maps product repository errors to responses.
+func (h *ProductHandler) respondProductError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, repositories.ErrSlugTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+	case errors.Is(err, repositories.ErrBrandNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Brand not found"})
+	case errors.Is(err, repositories.ErrVariantExists), errors.Is(err, repositories.ErrSKUTaken), errors.Is(err, repositories.ErrBarcodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrUnknownOptionType), errors.Is(err, repositories.ErrDuplicateAttribute):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// respondVariantError maps variant repository errors to responses.
+func (h *ProductHandler) respondVariantError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, repositories.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+	case errors.Is(err, repositories.ErrVariantExists), errors.Is(err, repositories.ErrSKUTaken), errors.Is(err, repositories.ErrBarcodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrUnknownOptionType), errors.Is(err, repositories.ErrDuplicateAttribute):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// GetVariantBySKU godoc
+// @Summary Find a product variant by SKU
+// @Description Retrieve an active variant of an active product by its SKU. The SKU is not case-sensitive.
+// @Tags product-variants
+// @Accept  json
+// @Produce  json
+// @Param sku path string true "Variant SKU"
+// @Success 200 {object} models.ProductVariant
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /variants/by-sku/{sku} [get]
+func (h *ProductHandler) GetVariantBySKU(c *gin.Context) {
+	variant, err := h.repo.GetVariantBySKU(c.Request.Context(), normalizeSKU(c.Param("sku")))
+	if err != nil {
+		h.respondVariantError(c, err, "Failed to load product variant")
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// GetVariantByBarcode godoc
+// @Summary Find a product variant by barcode
+// @Description Retrieve an active variant of an active product by its EAN-8, UPC-A or EAN-13 barcode. A UPC-A code also matches the same code scanned as EAN-13.
+// @Tags product-variants
+// @Accept  json
+// @Produce  json
+// @Param code path string true "Barcode"
+// @Success 200 {object} models.ProductVariant
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /variants/by-barcode/{code} [get]
+func (h *ProductHandler) GetVariantByBarcode(c *gin.Context) {
+	code := strings.TrimSpace(c.Param("code"))
+	if !repositories.ValidBarcode(code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "barcode must be an EAN-8, UPC-A or EAN-13 code with a valid check digit"})
+		return
+	}
+
+	variant, err := h.repo.GetVariantByBarcode(c.Request.Context(), code)
+	if err != nil {
+		h.respondVariantError(c, err, "Failed to load product variant")
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
 // ToggleVariantActive godoc
 // @Summary Toggle product variant active status
 // @Description Toggle the active status of a product variant (activate/deactivate)
@@ -358,14 +787,24 @@ func (h *ProductHandler) SoftDelete(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Product Variant ID"
 // @Success 200 {object} models.ProductVariant
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /product-variants/{id}/toggle-active [patch]
 func (h *ProductHandler) ToggleVariantActive(c *gin.Context) {
 	id := c.Param("id")
 
-	variant, err := h.repo.ToggleVariantActive(c.Request.Context(), id, nil)
+	if !isUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+		return
+	}
+
+	variant, err := h.repo.ToggleVariantActive(c.Request.Context(), id, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle product variant active status"})
+		h.respondVariantError(c, err, "Failed to toggle product variant active status")
 		return
 	}
 
@@ -380,14 +819,24 @@ func (h *ProductHandler) ToggleVariantActive(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Product Variant ID"
 // @Success 200 {object} models.ProductVariant
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /product-variants/{id}/soft-delete [delete]
 func (h *ProductHandler) SoftDeleteVariant(c *gin.Context) {
 	id := c.Param("id")
 
-	variant, err := h.repo.SoftDeleteVariant(c.Request.Context(), id, nil)
+	if !isUUID(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product variant not found"})
+		return
+	}
+
+	variant, err := h.repo.SoftDeleteVariant(c.Request.Context(), id, currentUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to soft delete product variant"})
+		h.respondVariantError(c, err, "Failed to soft delete product variant")
 		return
 	}
 
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/config"
+	"clothes-shop-api/internal/imaging"
+	"clothes-shop-api/internal/repositories"
+	"clothes-shop-api/internal/storage"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ProductImageHandler struct {
+	repo          *repositories.ProductImageRepository
+	storage       storage.Storage
+	maxBytes      int64
+	thumbnailSize int
+}
+
+type ReorderImagesRequest struct {
+	// VariantID selects the gallery of a variant; omit it for the product gallery.
+	VariantID *string `json:"variant_id" binding:"omitempty,uuid"`
+	// ImageIDs lists every image of the gallery in the new order.
+	ImageIDs []string `json:"image_ids" binding:"required,dive,uuid"`
+}
+
+// maxAltTextLength limits the alternative text of an image.
+const maxAltTextLength = 300
+
+// imageExtensions maps the accepted image types to the extension of their stored files.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func NewProductImageHandler(repo *repositories.ProductImageRepository, store storage.Storage, cfg config.Config) *ProductImageHandler {
+	thumbnailSize := cfg.ImageThumbnailSize
+	if thumbnailSize <= 0 {
+		thumbnailSize = 400
+	}
+	return &ProductImageHandler{repo: repo, storage: store, maxBytes: int64(cfg.ImageMaxBytes), thumbnailSize: thumbnailSize}
+}
+
+// GetProductImages godoc
+// @Summary Get product images
+// @Description Retrieve the image gallery of an active product followed by the galleries of its variants, each in display order
+// @Tags product-images
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Success 200 {object} ListResponse[models.ProductImage]
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/images [get]
+func (h *ProductImageHandler) GetProductImages(c *gin.Context) {
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	images, err := h.repo.GetProductImages(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "Failed to load product images")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(images))
+}
+
+// UploadProductImage godoc
+// @Summary Upload a product image
+// @Description Upload a JPEG, PNG or GIF image and add it to the end of the product gallery, or of a variant gallery when variant_id is given. A thumbnail is generated from the image.
+// @Tags product-images
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param file formData file true "Image file"
+// @Param variant_id formData string false "Variant ID, for the gallery of a variant"
+// @Param alt_text formData string false "Alternative text"
+// @Success 201 {object} models.ProductImage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /products/{id}/images [post]
+func (h *ProductImageHandler) UploadProductImage(c *gin.Context) {
+	productID := c.Param("id")
+	if !isUUID(productID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	// Leave room for the other form fields and the multipart framing
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondTooLarge(c)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if file.Size > h.maxBytes {
+		h.respondTooLarge(c)
+		return
+	}
+
+	input := repositories.ProductImageInput{AltText: strings.TrimSpace(c.PostForm("alt_text"))}
+	if len(input.AltText) > maxAltTextLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alt_text may be at most 300 characters"})
+		return
+	}
+	if variantID := c.PostForm("variant_id"); variantID != "" {
+		if !isUUID(variantID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "variant_id must be a UUID"})
+			return
+		}
+		input.VariantID = &variantID
+	}
+
+	data, err := readFormFile(file, h.maxBytes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	thumbnail, thumbnailType, err := img.Thumbnail(h.thumbnailSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate thumbnail"})
+		return
+	}
+
+	input.ID = uuid.NewString()
+	input.StorageKey = "products/" + productID + "/" + input.ID + imageExtensions[img.ContentType]
+	input.ThumbnailKey = "products/" + productID + "/" + input.ID + "_thumb" + imageExtensions[thumbnailType]
+	input.URL = h.storage.URL(input.StorageKey)
+	input.ThumbnailURL = h.storage.URL(input.ThumbnailKey)
+	input.ContentType = img.ContentType
+	input.SizeBytes = int64(len(data))
+	input.Width, input.Height = img.Width, img.Height
+
+	ctx := c.Request.Context()
+	if err := h.storage.Put(ctx, input.StorageKey, data, img.ContentType); err != nil {
+		log.Printf("Failed to store image %s: %v", input.StorageKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := h.storage.Put(ctx, input.ThumbnailKey, thumbnail, thumbnailType); err != nil {
+		log.Printf("Failed to store image %s: %v", input.ThumbnailKey, err)
+		h.deleteStored(c, input.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	image, err := h.repo.CreateProductImage(ctx, productID, input, currentUserID(c))
+	if err != nil {
+		h.deleteStored(c, input.StorageKey, input.ThumbnailKey)
+		h.respondError(c, err, "Failed to save product image")
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+// ReorderProductImages godoc
+// @Summary Reorder a product image gallery
+// @Description Set the display order of the product gallery, or of a variant gallery when variant_id is given. The request must list every image of the gallery.
+// @Tags product-images
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param request body ReorderImagesRequest true "New order"
+// @Success 200 {object} ListResponse[models.ProductImage]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /products/{id}/images/order [put]
+func (h *ProductImageHandler) ReorderProductImages(c *gin.Context) {
+	var req ReorderImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isUUID(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	images, err := h.repo.ReorderProductImages(c.Request.Context(), c.Param("id"), req.VariantID, req.ImageIDs, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to reorder product images")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCollectionResponse(images))
+}
+
+// DeleteProductImage godoc
+// @Summary Delete a product image
+// @Description Remove an image from its gallery (soft delete)
+// @Tags product-images
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Product ID"
+// @Param imageId path string true "Image ID"
+// @Success 200 {object} models.ProductImage
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /products/{id}/images/{imageId} [delete]
+func (h *ProductImageHandler) DeleteProductImage(c *gin.Context) {
+	productID, imageID := c.Param("id"), c.Param("imageId")
+	if !isUUID(productID) || !isUUID(imageID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product image not found"})
+		return
+	}
+
+	image, err := h.repo.SoftDeleteProductImage(c.Request.Context(), productID, imageID, currentUserID(c))
+	if err != nil {
+		h.respondError(c, err, "Failed to delete product image")
+		return
+	}
+
+	c.JSON(http.StatusOK, image)
+}
+
+// readFormFile reads an uploaded file of at most maxBytes.
+func readFormFile(file *multipart.FileHeader, maxBytes int64) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errors.New("file is too large")
+	}
+	return data, nil
+}
+
+// deleteStored removes files stored for an upload that could not be saved.
+func (h *ProductImageHandler) deleteStored(c *gin.Context, keys ...string) {
+	for _, key := range keys {
+		if err := h.storage.Delete(c.Request.Context(), key); err != nil {
+			log.Printf("Failed to delete stored image %s: %v", key, err)
+		}
+	}
+}
+
+func (h *ProductImageHandler) respondTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file may be at most " + strconv.FormatInt(h.maxBytes, 10) + " bytes"})
+}
+
+// respondError maps product image repository errors to responses.
+func (h *ProductImageHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, repositories.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product image not found"})
+	case errors.Is(err, repositories.ErrVariantNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The variant does not belong to this product"})
+	case errors.Is(err, repositories.ErrImageOrderMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/config"
+	"clothes-shop-api/internal/dto"
+	"clothes-shop-api/internal/mailer"
+	"clothes-shop-api/internal/middleware"
+	"clothes-shop-api/internal/repositories"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileHandler struct {
+	userRepo         *repositories.UserRepository
+	refreshTokenRepo *repositories.RefreshTokenRepository
+	verificationRepo *repositories.EmailVerificationRepository
+	mailer           mailer.Sender
+	cfg              config.Config
+}
+
+type UpdateProfileRequest struct {
+	FullName      *string `json:"full_name" binding:"omitempty,max=100"`
+	Phone         *string `json:"phone" binding:"omitempty,max=20"`
+	DateOfBirth   *string `json:"date_of_birth" example:"1995-08-21"`
+	PreferredSize *string `json:"preferred_size" binding:"omitempty,max=10"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type DeactivateAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+func NewProfileHandler(userRepo *repositories.UserRepository, refreshTokenRepo *repositories.RefreshTokenRepository, verificationRepo *repositories.EmailVerificationRepository, mail mailer.Sender, cfg config.Config) *ProfileHandler {
+	return &ProfileHandler{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mail,
+		cfg:              cfg,
+	}
+}
+
+// GetMe godoc
+// @Summary Get my profile
+// @Description Retrieve the authenticated user's account and profile
+// @Tags users
+// @Produce  json
+// @Success 200 {object} dto.UserResponse
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me [get]
+func (h *ProfileHandler) GetMe(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// UpdateMe godoc
+// @Summary Update my profile
+// @Description Update profile fields of the authenticated user. Omitted fields are left unchanged; send an empty date_of_birth to clear it.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body UpdateProfileRequest true "Profile fields to change"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me [patch]
+func (h *ProfileHandler) UpdateMe(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DateOfBirth != nil && *req.DateOfBirth != "" {
+		dob, err := time.Parse("2006-01-02", *req.DateOfBirth)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_of_birth must be in YYYY-MM-DD format"})
+			return
+		}
+		if dob.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_of_birth cannot be in the future"})
+			return
+		}
+	}
+
+	if req.FullName != nil {
+		trimmed := strings.TrimSpace(*req.FullName)
+		req.FullName = &trimmed
+	}
+
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.UpdateProfile(c.Request.Context(), userID, repositories.UserProfileUpdate{
+		FullName:      req.FullName,
+		Phone:         req.Phone,
+		DateOfBirth:   req.DateOfBirth,
+		PreferredSize: req.PreferredSize,
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// ChangeEmail godoc
+// @Summary Change my email
+// @Description Send a verification link to the new address. The account email changes only after the link is opened; the current address is notified.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body ChangeEmailRequest true "New email and current password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me/email [post]
+func (h *ProfileHandler) ChangeEmail(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	if !h.userRepo.CheckPassword(user.Password, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if strings.EqualFold(req.NewEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New email must be different from the current email"})
+		return
+	}
+
+	taken, err := h.userRepo.IsEmailTaken(ctx, req.NewEmail, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "This email is already registered to another account"})
+		return
+	}
+
+	err = sendVerificationEmail(ctx, h.verificationRepo, h.mailer, h.cfg, userID, req.NewEmail,
+		"You asked to change the email address of your Clothes Shop account to this address.\n\nOpen this link to confirm the change:")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	err = h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body:    "A request was made to change the email address of your Clothes Shop account to " + req.NewEmail + ".\n\nIf this was not you, change your password immediately.",
+	})
+	if err != nil {
+		log.Println("Change email: failed to notify current address:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "A verification link has been sent to the new email address"})
+}
+
+// DeactivateMe godoc
+// @Summary Deactivate my account
+// @Description Deactivate the authenticated user's account (is_active = false) and end all sessions. The account can only be reactivated by an admin.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param request body DeactivateAccountRequest true "Current password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/me/deactivate [post]
+func (h *ProfileHandler) DeactivateMe(c *gin.Context) {
+	var req DeactivateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, _ := middleware.GetUserID(c)
+
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	if !h.userRepo.CheckPassword(user.Password, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if _, err := h.userRepo.Deactivate(ctx, userID, &userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate account"})
+		return
+	}
+
+	if err := h.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		log.Println("Deactivate account: failed to revoke sessions:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deactivated"})
+}
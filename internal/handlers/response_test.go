@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"clothes-shop-api/internal/dto"
+	"clothes-shop-api/internal/models"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// responseTypes lists every type the handlers serialize as a response body.
+// Add new response types here so they are checked as well.
+var responseTypes = []any{
+	AuthResponse{},
+	TokenResponse{},
+	MFAChallengeResponse{},
+	TOTPSetupResponse{},
+	TOTPConfirmResponse{},
+	RecoveryCodesResponse{},
+	ImpersonationResponse{},
+	CreateAPIKeyResponse{},
+	OAuthLoginResponse{},
+	ProductListResponse{},
+	ListResponse[dto.AdminUserResponse]{},
+	ListResponse[models.SecurityEvent]{},
+	ListResponse[models.Category]{},
+	ListResponse[models.Brand]{},
+	ListResponse[models.OptionType]{},
+	ListResponse[models.ProductImage]{},
+	ListResponse[models.APIKey]{},
+	dto.UserResponse{},
+	dto.AdminUserResponse{},
+	models.Product{},
+	models.ProductVariant{},
+	models.ProductImage{},
+	models.Category{},
+	models.Brand{},
+	models.OptionType{},
+	models.SecurityEvent{},
+	models.APIKey{},
+}
+
+// forbiddenJSONFields are field names that must never appear in a response.
+var forbiddenJSONFields = []string{"password", "hash", "totp_secret", "totp_last_counter"}
+
+// allowedSecretFields are intentionally returned to the account owner once.
+var allowedSecretFields = map[string]bool{
+	"TOTPSetupResponse.secret": true,
+}
+
+func TestResponseTypesDoNotExposeSecrets(t *testing.T) {
+	for _, v := range responseTypes {
+		typ := reflect.TypeOf(v)
+		checkJSONFields(t, typ.Name(), typ, map[reflect.Type]bool{})
+	}
+}
+
+func checkJSONFields(t *testing.T, root string, typ reflect.Type, seen map[reflect.Type]bool) {
+	t.Helper()
+
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			checkJSONFields(t, root, field.Type, seen)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		lower := strings.ToLower(name)
+		for _, forbidden := range forbiddenJSONFields {
+			if strings.Contains(lower, forbidden) {
+				t.Errorf("%s serializes %s.%s as %q", root, typ.Name(), field.Name, name)
+			}
+		}
+		if strings.Contains(lower, "secret") && !allowedSecretFields[typ.Name()+"."+name] {
+			t.Errorf("%s serializes %s.%s as %q", root, typ.Name(), field.Name, name)
+		}
+
+		checkJSONFields(t, root, field.Type, seen)
+	}
+}
+
+func TestAuthResponseOmitsCredentials(t *testing.T) {
+	now := time.Now()
+	user := &models.User{
+		ID:              uuid.New(),
+		Email:           "jane@example.com",
+		Password:        "$2a$10$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXY",
+		Role:            "customer",
+		TOTPSecret:      "JBSWY3DPEHPK3PXP",
+		TOTPEnabledAt:   &now,
+		TOTPLastCounter: 12345678,
+	}
+
+	for name, v := range map[string]any{
+		"AuthResponse": AuthResponse{User: dto.NewUserResponse(user)},
+		"UserResponse": dto.NewUserResponse(user),
+		"models.User":  user,
+	} {
+		body, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", name, err)
+		}
+		for _, secret := range []string{user.Password, user.TOTPSecret, "12345678"} {
+			if strings.Contains(string(body), secret) {
+				t.Errorf("%s leaks %q: %s", name, secret, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels bounds the decoded size of an upload, so a small file cannot expand
+// into an image that exhausts memory. 16 megapixels decode to 64 MB.
+const MaxPixels = 16_000_000
+
+var (
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF file")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// Image is a decoded upload.
+type Image struct {
+	// ContentType is sniffed from the file contents, not taken from the request.
+	ContentType string
+	Width       int
+	Height      int
+	img         image.Image
+}
+
+// Decode checks that data is a JPEG, PNG or GIF image of a reasonable size and decodes it.
+func Decode(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	return &Image{ContentType: contentType, Width: config.Width, Height: config.Height, img: img}, nil
+}
+
+// Thumbnail scales the image down so its longer side is at most maxSide pixels
+// and encodes it. PNG images stay PNG to keep transparency; others become JPEG.
+// It returns the encoded thumbnail and its content type.
+func (i *Image) Thumbnail(maxSide int) ([]byte, string, error) {
+	width, height := i.Width, i.Height
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			width, height = maxSide, max(1, height*maxSide/width)
+		} else {
+			width, height = max(1, width*maxSide/height), maxSide
+		}
+	}
+
+	thumb := resize(i.img, width, height)
+
+	var buf bytes.Buffer
+	if i.ContentType == "image/png" {
+		if err := png.Encode(&buf, thumb); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// resize scales src to width x height by averaging the source pixels that fall
+// into each target pixel, which gives smooth results when shrinking. The source
+// is converted one band of rows at a time, so it is never copied at full size.
+func resize(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	band := image.NewRGBA(image.Rect(0, 0, srcWidth, (srcHeight+height-1)/height))
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		draw.Draw(band, image.Rect(0, 0, srcWidth, y1-y0), src, image.Pt(bounds.Min.X, bounds.Min.Y+y0), draw.Src)
+
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+
+			var r, g, b, a, n int
+			for sy := 0; sy < y1-y0; sy++ {
+				row := band.Pix[sy*band.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+
+			o := out.Pix[y*out.Stride+x*4:]
+			o[0], o[1], o[2], o[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers outgoing email. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the sender for the given driver: "file" writes each message to dir,
+// anything else logs messages to stdout.
+func New(driver, from, dir string) Sender {
+	switch driver {
+	case "file":
+		return &FileSender{From: from, Dir: dir}
+	default:
+		return &LogSender{From: from}
+	}
+}
+
+// LogSender writes messages to the application log. Intended for local development.
+type LogSender struct {
+	From string
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 Mail from=%s to=%s subject=%q\n%s", s.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender writes each message as a separate .eml file in Dir.
+type FileSender struct {
+	From string
+	Dir  string
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s_%s.eml", now.Format("20060102T150405.000000000"), recipient)
+
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		s.From, msg.To, msg.Subject, now.Format(time.RFC1123Z), msg.Body)
+
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte(content), 0o600)
+}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"clothes-shop-api/internal/auth"
+	"clothes-shop-api/internal/models"
+	"clothes-shop-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Context keys set by AuthRequired for downstream handlers.
+const (
+	ContextUserID        = "user_id"
+	ContextUserRole      = "user_role"
+	ContextEmailVerified = "email_verified"
+	ContextMFA           = "mfa"
+	ContextImpersonator  = "impersonated_by"
+	ContextAPIKeyID      = "api_key_id"
+	ContextAPIKeyScopes  = "api_key_scopes"
+	// ContextAPIKeyOwnerID is the admin who created the API key of the request.
+	ContextAPIKeyOwnerID = "api_key_owner_id"
+)
+
+// APIKeyHeader carries an API key as an alternative to a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyStore looks up API keys by hash and records their use.
+type APIKeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, ip string) error
+}
+
+// UserStore loads the account behind an access token.
+type UserStore interface {
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+}
+
+// AuthRequired validates the bearer token in the Authorization header and stores
+// the authenticated user's ID and role in the request context. The account is
+// loaded on every request, so deactivating or deleting it rejects its tokens at
+// once, and role and email verification changes apply without a new token.
+func AuthRequired(jwtSecret string, users UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			return
+		}
+
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || strings.TrimSpace(parts[1]) == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be in the format: Bearer <token>"})
+			return
+		}
+
+		claims, err := auth.ParseToken(jwtSecret, strings.TrimSpace(parts[1]), auth.TokenUseAccess)
+		if err != nil {
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		user, err := users.GetUserByID(c.Request.Context(), claims.UserID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		if !user.IsActive || user.IsDeleted {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "This account has been deactivated"})
+			return
+		}
+
+		c.Set(ContextUserID, claims.UserID)
+		c.Set(ContextUserRole, user.Role)
+		c.Set(ContextEmailVerified, user.EmailVerifiedAt != nil)
+		c.Set(ContextMFA, claims.MFA)
+		if claims.ImpersonatedBy != "" {
+			c.Set(ContextImpersonator, claims.ImpersonatedBy)
+		}
+		c.Next()
+	}
+}
+
+// AuthRequiredOrAPIKey authenticates the request with the X-API-Key header when
+// present and falls back to AuthRequired otherwise. Requests authenticated by a
+// key carry its scopes instead of a user and role.
+func AuthRequiredOrAPIKey(jwtSecret string, users UserStore, keys APIKeyStore) gin.HandlerFunc {
+	bearer := AuthRequired(jwtSecret, users)
+
+	return func(c *gin.Context) {
+		rawKey := strings.TrimSpace(c.GetHeader(APIKeyHeader))
+		if rawKey == "" {
+			bearer(c)
+			return
+		}
+
+		ctx := c.Request.Context()
+		key, err := keys.GetAPIKeyByHash(ctx, auth.HashToken(rawKey))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+
+		if key.ExpiresAt != nil && !time.Now().UTC().Before(*key.ExpiresAt) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key has expired"})
+			return
+		}
+
+		if !auth.IPAllowed(key.AllowedIPs, c.ClientIP()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is not allowed from this IP address"})
+			return
+		}
+
+		if err := keys.TouchAPIKey(ctx, key.ID, c.ClientIP()); err != nil {
+			log.Println("API key: failed to record last use:", err)
+		}
+
+		c.Set(ContextAPIKeyID, key.ID.String())
+		c.Set(ContextAPIKeyScopes, key.Scopes)
+		if key.CreatedBy != nil {
+			c.Set(ContextAPIKeyOwnerID, key.CreatedBy.String())
+		}
+		c.Next()
+	}
+}
+
+// GetUserID returns the authenticated user's ID from the request context.
+func GetUserID(c *gin.Context) (string, bool) {
+	userID := c.GetString(ContextUserID)
+	return userID, userID != ""
+}
+
+// GetUserRole returns the authenticated user's role from the request context.
+func GetUserRole(c *gin.Context) (string, bool) {
+	role := c.GetString(ContextUserRole)
+	return role, role != ""
+}
+
+// DenyImpersonation rejects requests made with an impersonation token. Routes that
+// change credentials or account ownership must be registered behind it.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextImpersonator) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action is not allowed while impersonating a user"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose authenticated role, or API key scopes,
+// do not grant perm. It must be registered after AuthRequired or AuthRequiredOrAPIKey.
+func RequirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes, ok := c.Get(ContextAPIKeyScopes); ok {
+			for _, scope := range scopes.([]string) {
+				if scope == string(perm) {
+					c.Next()
+					return
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is not allowed to perform this action"})
+			return
+		}
+
+		role, ok := GetUserRole(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		if !auth.HasPermission(role, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail rejects users whose email is not verified when required is
+// true. Checkout and review routes must be registered behind it.
+func RequireVerifiedEmail(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && !c.GetBool(ContextEmailVerified) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Please verify your email address before continuing"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireMFAForRoles rejects users holding one of roles whose session was not
+// authenticated with a second factor.
+func RequireMFAForRoles(roles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := GetUserRole(c)
+		for _, r := range roles {
+			if r == role && !c.GetBool(ContextMFA) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role, please enable it and log in again"})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,342 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"clothes-shop-api/internal/auth"
+	"clothes-shop-api/internal/models"
+	"clothes-shop-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs a GET request through handlers, followed by one that responds 200
+// with the role in the request context, and returns the response.
+func serve(header http.Header, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	r := gin.New()
+	// As in production without TRUSTED_PROXIES, X-Forwarded-For is not believed
+	r.SetTrustedProxies(nil)
+	handlers = append(handlers, func(c *gin.Context) { c.String(http.StatusOK, c.GetString(ContextUserRole)) })
+	r.GET("/", handlers...)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:4321"
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// withContext sets context values the way the authentication middleware does.
+func withContext(values map[string]any) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for key, value := range values {
+			c.Set(key, value)
+		}
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		context  map[string]any
+		want     int
+	}{
+		{"verified", true, map[string]any{ContextEmailVerified: true}, http.StatusOK},
+		{"unverified", true, map[string]any{ContextEmailVerified: false}, http.StatusForbidden},
+		{"not set", true, nil, http.StatusForbidden},
+		{"unverified when not required", false, map[string]any{ContextEmailVerified: false}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(nil, withContext(tt.context), RequireVerifiedEmail(tt.required)).Code; got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+const testSecret = "test-secret"
+
+// fakeUsers is a UserStore over a fixed set of accounts.
+type fakeUsers map[string]*models.User
+
+func (f fakeUsers) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	if id == "broken" {
+		return nil, errors.New("connection refused")
+	}
+	if user, ok := f[id]; ok {
+		return user, nil
+	}
+	return nil, repositories.ErrUserNotFound
+}
+
+var testUsers = fakeUsers{
+	"customer":    {Role: auth.RoleCustomer, BaseModel: models.BaseModel{IsActive: true}},
+	"demoted":     {Role: auth.RoleCustomer, BaseModel: models.BaseModel{IsActive: true}},
+	"verified":    {Role: auth.RoleCustomer, EmailVerifiedAt: new(time.Time), BaseModel: models.BaseModel{IsActive: true}},
+	"deactivated": {Role: auth.RoleCustomer},
+	"deleted":     {Role: auth.RoleCustomer, BaseModel: models.BaseModel{IsActive: true, IsDeleted: true}},
+}
+
+// token signs claims with secret.
+func token(t *testing.T, secret string, claims auth.Claims, ttl time.Duration) string {
+	t.Helper()
+	token, err := auth.GenerateToken(secret, claims, ttl)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+// bearer returns an Authorization header carrying a token with claims.
+func bearer(t *testing.T, secret string, claims auth.Claims, ttl time.Duration) http.Header {
+	t.Helper()
+	return http.Header{"Authorization": {"Bearer " + token(t, secret, claims, ttl)}}
+}
+
+func TestAuthRequired(t *testing.T) {
+	access := func(userID, role string) auth.Claims {
+		return auth.Claims{UserID: userID, Role: role, TokenUse: auth.TokenUseAccess}
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		want     int
+		wantRole string
+	}{
+		{name: "valid token", header: bearer(t, testSecret, access("customer", auth.RoleCustomer), time.Minute), want: http.StatusOK, wantRole: auth.RoleCustomer},
+		{name: "lower-case scheme", header: http.Header{"Authorization": {"bearer " + token(t, testSecret, access("customer", auth.RoleCustomer), time.Minute)}}, want: http.StatusOK, wantRole: auth.RoleCustomer},
+		{name: "no header", want: http.StatusUnauthorized},
+		{name: "other scheme", header: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}, want: http.StatusUnauthorized},
+		{name: "empty token", header: http.Header{"Authorization": {"Bearer "}}, want: http.StatusUnauthorized},
+		{name: "malformed token", header: http.Header{"Authorization": {"Bearer not.a.token"}}, want: http.StatusUnauthorized},
+		{name: "wrong secret", header: bearer(t, "other-secret", access("customer", auth.RoleCustomer), time.Minute), want: http.StatusUnauthorized},
+		{name: "expired", header: bearer(t, testSecret, access("customer", auth.RoleCustomer), -time.Minute), want: http.StatusUnauthorized},
+		{name: "MFA challenge token", header: bearer(t, testSecret, auth.Claims{UserID: "customer", TokenUse: auth.TokenUseMFAChallenge}, time.Minute), want: http.StatusUnauthorized},
+		{name: "role comes from the account", header: bearer(t, testSecret, access("demoted", auth.RoleAdmin), time.Minute), want: http.StatusOK, wantRole: auth.RoleCustomer},
+		{name: "deactivated account", header: bearer(t, testSecret, access("deactivated", auth.RoleCustomer), time.Minute), want: http.StatusUnauthorized},
+		{name: "deleted account", header: bearer(t, testSecret, access("deleted", auth.RoleCustomer), time.Minute), want: http.StatusUnauthorized},
+		{name: "unknown account", header: bearer(t, testSecret, access("missing", auth.RoleCustomer), time.Minute), want: http.StatusUnauthorized},
+		{name: "store failure", header: bearer(t, testSecret, access("broken", auth.RoleCustomer), time.Minute), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.header, AuthRequired(testSecret, testUsers))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != tt.wantRole {
+				t.Errorf("role = %q, want %q", w.Body.String(), tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestAuthRequiredReadsEmailVerificationFromTheAccount(t *testing.T) {
+	tests := []struct {
+		userID string
+		claim  bool
+		want   int
+	}{
+		{"verified", false, http.StatusOK},
+		{"customer", true, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		header := bearer(t, testSecret, auth.Claims{UserID: tt.userID, EmailVerified: tt.claim, TokenUse: auth.TokenUseAccess}, time.Minute)
+		if got := serve(header, AuthRequired(testSecret, testUsers), RequireVerifiedEmail(true)).Code; got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestDenyImpersonation(t *testing.T) {
+	tests := []struct {
+		name  string
+		token auth.Claims
+		want  int
+	}{
+		{"own session", auth.Claims{UserID: "customer", TokenUse: auth.TokenUseAccess}, http.StatusOK},
+		{"impersonation token", auth.Claims{UserID: "customer", TokenUse: auth.TokenUseAccess, ImpersonatedBy: "admin"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := bearer(t, testSecret, tt.token, time.Minute)
+			if got := serve(header, AuthRequired(testSecret, testUsers), DenyImpersonation()).Code; got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name    string
+		context map[string]any
+		perm    auth.Permission
+		want    int
+	}{
+		{"customer may place orders", map[string]any{ContextUserRole: auth.RoleCustomer}, auth.PermOrdersPlace, http.StatusOK},
+		{"customer may not write the catalog", map[string]any{ContextUserRole: auth.RoleCustomer}, auth.PermCatalogWrite, http.StatusForbidden},
+		{"staff may write the catalog", map[string]any{ContextUserRole: auth.RoleStaff}, auth.PermCatalogWrite, http.StatusOK},
+		{"staff may not manage users", map[string]any{ContextUserRole: auth.RoleStaff}, auth.PermUsersManage, http.StatusForbidden},
+		{"admin may manage users", map[string]any{ContextUserRole: auth.RoleAdmin}, auth.PermUsersManage, http.StatusOK},
+		{"unknown role", map[string]any{ContextUserRole: "owner"}, auth.PermOrdersPlace, http.StatusForbidden},
+		{"not authenticated", nil, auth.PermOrdersPlace, http.StatusUnauthorized},
+		{"API key with the scope", map[string]any{ContextAPIKeyScopes: []string{string(auth.PermCatalogWrite)}}, auth.PermCatalogWrite, http.StatusOK},
+		{"API key without the scope", map[string]any{ContextAPIKeyScopes: []string{string(auth.PermOrdersManage)}}, auth.PermCatalogWrite, http.StatusForbidden},
+		{"API key with no scopes", map[string]any{ContextAPIKeyScopes: []string{}}, auth.PermCatalogWrite, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(nil, withContext(tt.context), RequirePermission(tt.perm)).Code; got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireMFAForRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		context map[string]any
+		want    int
+	}{
+		{"admin without a second factor", []string{auth.RoleAdmin}, map[string]any{ContextUserRole: auth.RoleAdmin}, http.StatusForbidden},
+		{"admin with a second factor", []string{auth.RoleAdmin}, map[string]any{ContextUserRole: auth.RoleAdmin, ContextMFA: true}, http.StatusOK},
+		{"role that does not require it", []string{auth.RoleAdmin}, map[string]any{ContextUserRole: auth.RoleStaff}, http.StatusOK},
+		{"several roles", []string{auth.RoleAdmin, auth.RoleStaff}, map[string]any{ContextUserRole: auth.RoleStaff, ContextMFA: false}, http.StatusForbidden},
+		{"no roles", nil, map[string]any{ContextUserRole: auth.RoleAdmin}, http.StatusOK},
+		{"API key", []string{auth.RoleAdmin}, map[string]any{ContextAPIKeyScopes: []string{string(auth.PermCatalogWrite)}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(nil, withContext(tt.context), RequireMFAForRoles(tt.roles)).Code; got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAPIKeys is an APIKeyStore over a fixed set of keys, by raw key.
+type fakeAPIKeys struct {
+	keys map[string]*models.APIKey
+	// touched is the IP each key was last used from.
+	touched map[uuid.UUID]string
+}
+
+func (f *fakeAPIKeys) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	for raw, key := range f.keys {
+		if auth.HashToken(raw) == keyHash {
+			return key, nil
+		}
+	}
+	return nil, repositories.ErrAPIKeyNotFound
+}
+
+func (f *fakeAPIKeys) TouchAPIKey(ctx context.Context, id uuid.UUID, ip string) error {
+	f.touched[id] = ip
+	return nil
+}
+
+func TestAuthRequiredOrAPIKey(t *testing.T) {
+	owner := uuid.New()
+	expired := time.Now().Add(-time.Minute)
+	keys := &fakeAPIKeys{
+		keys: map[string]*models.APIKey{
+			"csk_valid":   {ID: uuid.New(), Scopes: []string{string(auth.PermCatalogWrite)}, BaseModel: models.BaseModel{CreatedBy: &owner}},
+			"csk_expired": {ID: uuid.New(), Scopes: []string{string(auth.PermCatalogWrite)}, ExpiresAt: &expired},
+			"csk_office":  {ID: uuid.New(), Scopes: []string{string(auth.PermCatalogWrite)}, AllowedIPs: []string{"198.51.100.0/24"}},
+			"csk_host":    {ID: uuid.New(), Scopes: []string{string(auth.PermCatalogWrite)}, AllowedIPs: []string{"192.0.2.1"}},
+		},
+		touched: map[uuid.UUID]string{},
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"valid key", http.Header{APIKeyHeader: {"csk_valid"}}, http.StatusOK},
+		{"unknown key", http.Header{APIKeyHeader: {"csk_unknown"}}, http.StatusUnauthorized},
+		{"expired key", http.Header{APIKeyHeader: {"csk_expired"}}, http.StatusUnauthorized},
+		{"allowed IP", http.Header{APIKeyHeader: {"csk_host"}}, http.StatusOK},
+		{"other IP", http.Header{APIKeyHeader: {"csk_office"}}, http.StatusForbidden},
+		{"spoofed X-Forwarded-For", http.Header{APIKeyHeader: {"csk_office"}, "X-Forwarded-For": {"198.51.100.7"}}, http.StatusForbidden},
+		{"bearer token", bearer(t, testSecret, auth.Claims{UserID: "customer", TokenUse: auth.TokenUseAccess}, time.Minute), http.StatusOK},
+		{"neither", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.header, AuthRequiredOrAPIKey(testSecret, testUsers, keys)).Code; got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if ip := keys.touched[keys.keys["csk_valid"].ID]; ip != "192.0.2.1" {
+		t.Errorf("last used IP = %q, want 192.0.2.1", ip)
+	}
+	if _, ok := keys.touched[keys.keys["csk_office"].ID]; ok {
+		t.Error("a rejected key was recorded as used")
+	}
+}
+
+func TestAPIKeyRequestsCarryScopesAndOwner(t *testing.T) {
+	owner := uuid.New()
+	keys := &fakeAPIKeys{
+		keys:    map[string]*models.APIKey{"csk_orders": {ID: uuid.New(), Scopes: []string{string(auth.PermOrdersManage)}, BaseModel: models.BaseModel{CreatedBy: &owner}}},
+		touched: map[uuid.UUID]string{},
+	}
+	header := http.Header{APIKeyHeader: {"csk_orders"}}
+
+	var gotOwner, gotUser string
+	capture := func(c *gin.Context) {
+		gotOwner = c.GetString(ContextAPIKeyOwnerID)
+		gotUser = c.GetString(ContextUserID)
+	}
+	if code := serve(header, AuthRequiredOrAPIKey(testSecret, testUsers, keys), capture).Code; code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if gotOwner != owner.String() || gotUser != "" {
+		t.Errorf("owner = %q, user = %q; want the key's creator and no user", gotOwner, gotUser)
+	}
+
+	tests := []struct {
+		perm auth.Permission
+		want int
+	}{
+		{auth.PermOrdersManage, http.StatusOK},
+		{auth.PermCatalogWrite, http.StatusForbidden},
+		{auth.PermUsersManage, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := serve(header, AuthRequiredOrAPIKey(testSecret, testUsers, keys), RequirePermission(tt.perm)).Code; got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.perm, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package models
 import "github.com/google/uuid"
 
 type Brand struct {
-	ID   uuid.UUID `json:"id"`
-	Name string    `json:"name"`
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	LogoURL     string    `json:"logo_url"`
 	BaseModel
 }
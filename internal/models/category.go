@@ -3,8 +3,17 @@ package models
 import "github.com/google/uuid"
 
 type Category struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
+	ID          uuid.UUID  `json:"id"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	// Children is only filled in by the category tree.
+	Children []Category `json:"children,omitempty"`
 	BaseModel
 }
+
+// Breadcrumb is one step on the path from a top-level category down to a category.
+type Breadcrumb struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
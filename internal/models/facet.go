@@ -0,0 +1,34 @@
+package models
+
+import "github.com/google/uuid"
+
+// FacetValue is one value of a product facet with the number of products that
+// match the current filters of the other facets.
+type FacetValue struct {
+	// ID is set for category and brand values.
+	ID    *uuid.UUID `json:"id,omitempty"`
+	Value string     `json:"value"`
+	Count int        `json:"count"`
+	// Selected is true when the value is one of the current filters.
+	Selected bool `json:"selected"`
+}
+
+// PriceRangeFacet is a configured price range with the number of matching products.
+type PriceRangeFacet struct {
+	// Key is the value to pass as price_range to filter on the range, e.g. "25-50".
+	Key string  `json:"key"`
+	Min float64 `json:"min"`
+	// Max is exclusive; nil means no upper bound.
+	Max      *float64 `json:"max"`
+	Count    int      `json:"count"`
+	Selected bool     `json:"selected"`
+}
+
+// ProductFacets holds the counts for the filter sidebar of a product listing.
+type ProductFacets struct {
+	Categories  []FacetValue      `json:"categories"`
+	Brands      []FacetValue      `json:"brands"`
+	Sizes       []FacetValue      `json:"sizes"`
+	Colors      []FacetValue      `json:"colors"`
+	PriceRanges []PriceRangeFacet `json:"price_ranges"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "github.com/google/uuid"
+
+// OptionType is a dimension that variants differ by, such as Size, Color or Inseam.
+type OptionType struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// BuiltIn is true for Size and Color, which are stored on the variant itself.
+	BuiltIn bool `json:"built_in"`
+	BaseModel
+}
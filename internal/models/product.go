@@ -5,14 +5,33 @@ import (
 )
 
 type Product struct {
-	ID          uuid.UUID        `json:"id" db:"id"`
-	Name        string           `json:"name" db:"name"`
-	Description string           `json:"description" db:"description"`
-	MinPrice    float64          `json:"min_price" db:"min_price"`
-	MaxPrice    float64          `json:"max_price" db:"max_price"`
-	TotalStock  int              `json:"total_stock" db:"total_stock"`
-	CategoryID  string           `json:"category_id" db:"category_id"`
-	BrandID     *uuid.UUID       `json:"brand_id" db:"brand_id"`
-	Variants    []ProductVariant `json:"variants" db:"variants"`
+	ID          uuid.UUID         `json:"id" db:"id"`
+	Name        string            `json:"name" db:"name"`
+	Slug        string            `json:"slug" db:"slug"`
+	Description string            `json:"description" db:"description"`
+	MinPrice    float64           `json:"min_price" db:"min_price"`
+	MaxPrice    float64           `json:"max_price" db:"max_price"`
+	TotalStock  int               `json:"total_stock" db:"total_stock"`
+	SoldCount   int               `json:"sold_count" db:"sold_count"`
+	RatingAvg   float64           `json:"rating_avg" db:"rating_avg"`
+	RatingCount int               `json:"rating_count" db:"rating_count"`
+	CategoryID  string            `json:"category_id" db:"category_id"`
+	BrandID     *uuid.UUID        `json:"brand_id" db:"brand_id"`
+	Category    *Category         `json:"category,omitempty" db:"-"`
+	Breadcrumbs []Breadcrumb      `json:"breadcrumbs,omitempty" db:"-"`
+	Brand       *Brand            `json:"brand,omitempty" db:"-"`
+	Attributes  map[string]string `json:"attributes,omitempty" db:"-"`
+	Images      []ProductImage    `json:"images,omitempty" db:"-"`
+	Variants    []ProductVariant  `json:"variants" db:"variants"`
+	// SearchRank and Highlight are only set in search results.
+	SearchRank *float64          `json:"search_rank,omitempty" db:"-"`
+	Highlight  *ProductHighlight `json:"highlight,omitempty" db:"-"`
 	BaseModel
 }
+
+// ProductHighlight holds the name and a description snippet of a search result
+// with the matched words wrapped in <mark> tags.
+type ProductHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
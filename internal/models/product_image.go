@@ -0,0 +1,22 @@
+package models
+
+import "github.com/google/uuid"
+
+// ProductImage is an uploaded image in the gallery of a product or of one of its variants.
+type ProductImage struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	ProductID string    `json:"product_id" db:"product_id"`
+	// VariantID is set for images of a single variant and nil for the product gallery.
+	VariantID    *uuid.UUID `json:"variant_id" db:"variant_id"`
+	URL          string     `json:"url" db:"url"`
+	ThumbnailURL string     `json:"thumbnail_url" db:"thumbnail_url"`
+	StorageKey   string     `json:"-" db:"storage_key"`
+	ThumbnailKey string     `json:"-" db:"thumbnail_key"`
+	ContentType  string     `json:"content_type" db:"content_type"`
+	SizeBytes    int64      `json:"size_bytes" db:"size_bytes"`
+	Width        int        `json:"width" db:"width"`
+	Height       int        `json:"height" db:"height"`
+	AltText      string     `json:"alt_text" db:"alt_text"`
+	Position     int        `json:"position" db:"position"`
+	BaseModel
+}
@@ -5,10 +5,14 @@ import "github.com/google/uuid"
 type ProductVariant struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	ProductID string    `json:"product_id" db:"product_id"`
+	SKU       string    `json:"sku" db:"sku"`
+	Barcode   *string   `json:"barcode" db:"barcode"`
 	Size      string    `json:"size" db:"size"`
 	Color     string    `json:"color" db:"color"`
 	Stock     int       `json:"stock" db:"stock"`
 	Price     float64   `json:"price" db:"price"`
 	Image     string    `json:"image" db:"image"`
+	// Options holds the values of option types other than size and color, keyed by option type name.
+	Options map[string]string `json:"options,omitempty" db:"-"`
 	BaseModel
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Security event types.
+const (
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventIPLocked        = "ip_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventIPUnlocked      = "ip_unlocked"
+	SecurityEventImpersonation   = "user_impersonated"
+	SecurityEventPasswordReset   = "password_reset_forced"
+)
+
+type SecurityEvent struct {
+	ID        uuid.UUID  `json:"id"`
+	EventType string     `json:"event_type"`
+	UserID    *uuid.UUID `json:"user_id"`
+	Email     string     `json:"email,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"`
+	Details   string     `json:"details,omitempty"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	CreatedAt time.Time  `json:"created_at"`
+}
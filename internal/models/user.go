@@ -1,11 +1,23 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type User struct {
-	ID       uuid.UUID `json:"id"`
-	Email    string    `json:"email"`
-	Password string    `json:"password"`
-	Role     string    `json:"role"`
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Role            string     `json:"role"`
+	FullName        string     `json:"full_name"`
+	Phone           string     `json:"phone"`
+	DateOfBirth     *string    `json:"date_of_birth"` // YYYY-MM-DD
+	PreferredSize   string     `json:"preferred_size"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`
+	TOTPLastCounter int64      `json:"-"`
 	BaseModel
 }
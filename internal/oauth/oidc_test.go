@@ -0,0 +1,280 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "shop-client"
+
+// fakeIssuer is an OpenID Connect issuer that checks PKCE and returns ID tokens
+// built by idToken.
+type fakeIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu sync.Mutex
+	// pending maps authorization codes to their PKCE challenge and nonce.
+	pending map[string][2]string
+	idToken func(nonce string) string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &fakeIssuer{key: key, pending: map[string][2]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mu.Lock()
+		pending, ok := issuer.pending[r.FormValue("code")]
+		delete(issuer.pending, r.FormValue("code"))
+		issuer.mu.Unlock()
+
+		if !ok || r.FormValue("client_id") != testClientID || CodeChallenge(r.FormValue("code_verifier")) != pending[0] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": issuer.idToken(pending[1])})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+// authorize signs the user in at the authorization URL and returns the code.
+func (f *fakeIssuer) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID || q.Get("response_type") != "code" {
+		t.Fatalf("authorization URL %s does not request the code flow with S256 PKCE", authURL)
+	}
+
+	code := "code-" + q.Get("state")
+	f.mu.Lock()
+	f.pending[code] = [2]string{q.Get("code_challenge"), q.Get("nonce")}
+	f.mu.Unlock()
+	return code
+}
+
+// sign returns claims signed with the issuer's key.
+func (f *fakeIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(f.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge = %q", got)
+	}
+}
+
+func TestRandomStringIsUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		s, err := RandomString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s) != 43 || seen[s] {
+			t.Fatalf("RandomString = %q, want 43 unique characters", s)
+		}
+		seen[s] = true
+	}
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// claims changes the ID token claims issued for the login's nonce.
+		claims func(c jwt.MapClaims)
+		// sign replaces the issuer's RS256 signature.
+		sign func(c jwt.MapClaims) string
+		// wrongVerifier and wrongNonce redeem the code with values other than the login's.
+		wrongVerifier bool
+		wrongNonce    bool
+		want          *Identity
+		wantErr       bool
+	}{
+		{
+			name: "valid login",
+			want: &Identity{Subject: "user-1", Email: "ann@example.com", EmailVerified: true, Name: "Ann"},
+		},
+		{
+			name:   "email_verified as a string",
+			claims: func(c jwt.MapClaims) { c["email_verified"] = "true" },
+			want:   &Identity{Subject: "user-1", Email: "ann@example.com", EmailVerified: true, Name: "Ann"},
+		},
+		{
+			name:   "unverified email",
+			claims: func(c jwt.MapClaims) { c["email_verified"] = false },
+			want:   &Identity{Subject: "user-1", Email: "ann@example.com", Name: "Ann"},
+		},
+		{name: "wrong PKCE verifier", wrongVerifier: true, wantErr: true},
+		{name: "nonce of another login", wrongNonce: true, wantErr: true},
+		{name: "no nonce", claims: func(c jwt.MapClaims) { delete(c, "nonce") }, wantErr: true},
+		{name: "other audience", claims: func(c jwt.MapClaims) { c["aud"] = "other-client" }, wantErr: true},
+		{name: "other issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: true},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-5 * time.Minute).Unix() }, wantErr: true},
+		{name: "no expiry", claims: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "no subject", claims: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: true},
+		{
+			name: "signed with another key",
+			sign: func(c jwt.MapClaims) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+				token.Header["kid"] = "k1"
+				signed, _ := token.SignedString(otherKey)
+				return signed
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 with the client ID as secret",
+			sign: func(c jwt.MapClaims) string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(testClientID))
+				return signed
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsigned",
+			sign: func(c jwt.MapClaims) string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return signed
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			issuer.idToken = func(nonce string) string {
+				claims := jwt.MapClaims{
+					"iss":            issuer.URL,
+					"aud":            testClientID,
+					"sub":            "user-1",
+					"exp":            time.Now().Add(5 * time.Minute).Unix(),
+					"nonce":          nonce,
+					"email":          "ann@example.com",
+					"email_verified": true,
+					"name":           "Ann",
+				}
+				if tt.claims != nil {
+					tt.claims(claims)
+				}
+				if tt.sign != nil {
+					return tt.sign(claims)
+				}
+				return issuer.sign(t, claims)
+			}
+
+			p := NewOIDCProvider(OIDCConfig{Name: "test", IssuerURL: issuer.URL, ClientID: testClientID, RedirectURL: "http://localhost/callback", Scopes: []string{"openid", "email"}})
+			state, _ := RandomString()
+			nonce, _ := RandomString()
+			verifier, _ := RandomString()
+
+			authURL, err := p.AuthCodeURL(ctx, state, nonce, CodeChallenge(verifier))
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			code := issuer.authorize(t, authURL)
+
+			if tt.wrongVerifier {
+				verifier, _ = RandomString()
+			}
+			if tt.wrongNonce {
+				nonce, _ = RandomString()
+			}
+
+			identity, err := p.Exchange(ctx, code, verifier, nonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Exchange = %+v, want an error", identity)
+				}
+				if !tt.wrongVerifier && !errors.Is(err, ErrInvalidIDToken) {
+					t.Errorf("Exchange error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if *identity != *tt.want {
+				t.Errorf("Exchange = %+v, want %+v", identity, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderRejectsMismatchedDiscoveryIssuer(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	p := NewOIDCProvider(OIDCConfig{Name: "test", IssuerURL: issuer.URL + "/tenant", ClientID: testClientID})
+
+	// The discovery document of another issuer is served under this URL
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, issuer.URL+"/.well-known/openid-configuration", http.StatusFound)
+	})
+	proxy := httptest.NewServer(mux)
+	defer proxy.Close()
+	p.cfg.IssuerURL = proxy.URL + "/tenant"
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Error("AuthCodeURL accepted a discovery document of another issuer")
+	}
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// apiKeyColumns is the column list read by scanAPIKey.
+const apiKeyColumns = `id, name, key_prefix, key_hash, scopes, allowed_ips, expires_at, last_used_at, last_used_ip,
+	created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+// apiKeyTouchInterval limits how often last_used_at is written for a busy key.
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+// scanAPIKey reads a row selected with apiKeyColumns.
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.KeyHash, &key.Scopes, &key.AllowedIPs, &key.ExpiresAt, &key.LastUsedAt, &key.LastUsedIP,
+		&key.CreatedBy, &key.CreatedAt, &key.UpdatedBy, &key.UpdatedAt, &key.IsActive, &key.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key models.APIKey, createdBy *string) (*models.APIKey, error) {
+	query := `
+		INSERT INTO api_keys (name, key_prefix, key_hash, scopes, allowed_ips, expires_at, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING ` + apiKeyColumns
+
+	return scanAPIKey(r.DB.QueryRow(ctx, query, key.Name, key.KeyPrefix, key.KeyHash, key.Scopes, key.AllowedIPs, key.ExpiresAt, createdBy))
+}
+
+func (r *APIKeyRepository) GetAllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE is_deleted = false
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash returns the active, non-deleted key with keyHash.
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1 AND is_active = true AND is_deleted = false
+	`
+
+	return scanAPIKey(r.DB.QueryRow(ctx, query, keyHash))
+}
+
+// TouchAPIKey records that the key was used from ip. Writes are skipped when the
+// key was already marked as used within apiKeyTouchInterval.
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id uuid.UUID, ip string) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2, last_used_ip = $3
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $4)
+	`
+
+	_, err := r.DB.Exec(ctx, query, id, now, ip, now.Add(-apiKeyTouchInterval))
+	return err
+}
+
+// SoftDelete revokes the key.
+func (r *APIKeyRepository) SoftDelete(ctx context.Context, id string, updatedBy *string) (*models.APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET is_deleted = true, is_active = false, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + apiKeyColumns
+
+	return scanAPIKey(r.DB.QueryRow(ctx, query, id, updatedBy))
+}
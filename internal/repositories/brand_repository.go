@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrBrandNotFound  = errors.New("brand not found")
+	ErrBrandNameTaken = errors.New("a brand with this name already exists")
+	ErrBrandInUse     = errors.New("brand is still used by products")
+)
+
+// brandColumns is the column list read by scanBrand.
+const brandColumns = `id, name, COALESCE(description, ''), logo_url, created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+type BrandRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewBrandRepository(db *pgxpool.Pool) *BrandRepository {
+	return &BrandRepository{DB: db}
+}
+
+// scanBrand reads a row selected with brandColumns.
+func scanBrand(row pgx.Row) (*models.Brand, error) {
+	var brand models.Brand
+	err := row.Scan(&brand.ID, &brand.Name, &brand.Description, &brand.LogoURL,
+		&brand.CreatedBy, &brand.CreatedAt, &brand.UpdatedBy, &brand.UpdatedAt, &brand.IsActive, &brand.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrBrandNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrBrandNameTaken
+		}
+		return nil, err
+	}
+	return &brand, nil
+}
+
+// GetAllBrands returns active brands that are not deleted.
+func (r *BrandRepository) GetAllBrands(ctx context.Context) ([]models.Brand, error) {
+	query := `
+		SELECT ` + brandColumns + `
+		FROM brands
+		WHERE is_active = true AND is_deleted = false
+		ORDER BY name
+	`
+
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brands := []models.Brand{}
+	for rows.Next() {
+		brand, err := scanBrand(rows)
+		if err != nil {
+			return nil, err
+		}
+		brands = append(brands, *brand)
+	}
+
+	return brands, rows.Err()
+}
+
+func (r *BrandRepository) GetBrandByID(ctx context.Context, id string) (*models.Brand, error) {
+	query := `
+		SELECT ` + brandColumns + `
+		FROM brands
+		WHERE id = $1 AND is_deleted = false
+	`
+
+	return scanBrand(r.DB.QueryRow(ctx, query, id))
+}
+
+func (r *BrandRepository) CreateBrand(ctx context.Context, name, description, logoURL string, createdBy *string) (*models.Brand, error) {
+	query := `
+		INSERT INTO brands (name, description, logo_url, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING ` + brandColumns
+
+	return scanBrand(r.DB.QueryRow(ctx, query, name, description, logoURL, createdBy))
+}
+
+func (r *BrandRepository) UpdateBrand(ctx context.Context, id, name, description, logoURL string, updatedBy *string) (*models.Brand, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE brands
+		SET name = $2, description = $3, logo_url = $4, updated_by = $5, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + brandColumns
+
+	brand, err := scanBrand(tx.QueryRow(ctx, query, id, name, description, logoURL, updatedBy))
+	if err != nil {
+		return nil, err
+	}
+
+	// Brand names are part of the product search document
+	if err := refreshProductSearch(ctx, tx, "p.brand_id = $1", id); err != nil {
+		return nil, err
+	}
+
+	return brand, tx.Commit(ctx)
+}
+
+// SoftDelete marks the brand as deleted. It fails with ErrBrandInUse while
+// any product that is not deleted references it.
+func (r *BrandRepository) SoftDelete(ctx context.Context, id string, updatedBy *string) (*models.Brand, error) {
+	query := `
+		UPDATE brands
+		SET is_deleted = true, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+			AND NOT EXISTS (SELECT 1 FROM products WHERE brand_id = $1 AND is_deleted = false)
+		RETURNING ` + brandColumns
+
+	brand, err := scanBrand(r.DB.QueryRow(ctx, query, id, updatedBy))
+	if errors.Is(err, ErrBrandNotFound) {
+		if _, getErr := r.GetBrandByID(ctx, id); getErr == nil {
+			return nil, ErrBrandInUse
+		}
+	}
+	return brand, err
+}
+
+// Restore undoes SoftDelete. It fails with ErrBrandNameTaken when another
+// brand took the name in the meantime.
+func (r *BrandRepository) Restore(ctx context.Context, id string, updatedBy *string) (*models.Brand, error) {
+	query := `
+		UPDATE brands
+		SET is_deleted = false, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = true
+		RETURNING ` + brandColumns
+
+	return scanBrand(r.DB.QueryRow(ctx, query, id, updatedBy))
+}
@@ -0,0 +1,305 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrCategoryNotFound       = errors.New("category not found")
+	ErrCategoryNameTaken      = errors.New("a category with this name already exists")
+	ErrCategoryInUse          = errors.New("category is still used by products")
+	ErrCategoryHasChildren    = errors.New("category still has subcategories")
+	ErrParentCategoryNotFound = errors.New("parent category not found")
+	ErrParentCategoryDeleted  = errors.New("parent category is deleted")
+	ErrCategoryCycle          = errors.New("a category cannot be moved under itself or one of its subcategories")
+)
+
+// maxCategoryDepth bounds the walk up the tree when building breadcrumbs.
+const maxCategoryDepth = 32
+
+// categoryColumns is the column list read by scanCategory.
+const categoryColumns = `id, parent_id, name, COALESCE(description, ''), created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+type CategoryRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewCategoryRepository(db *pgxpool.Pool) *CategoryRepository {
+	return &CategoryRepository{DB: db}
+}
+
+// scanCategory reads a row selected with categoryColumns.
+func scanCategory(row pgx.Row) (*models.Category, error) {
+	var category models.Category
+	err := row.Scan(&category.ID, &category.ParentID, &category.Name, &category.Description,
+		&category.CreatedBy, &category.CreatedAt, &category.UpdatedBy, &category.UpdatedAt, &category.IsActive, &category.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrCategoryNameTaken
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetAllCategories returns active categories that are not deleted.
+func (r *CategoryRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	query := `
+		SELECT ` + categoryColumns + `
+		FROM categories
+		WHERE is_active = true AND is_deleted = false
+		ORDER BY name
+	`
+
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategoryTree returns the active categories nested under their parents. A
+// category whose parent is inactive is left out together with its subtree.
+func (r *CategoryRepository) GetCategoryTree(ctx context.Context) ([]models.Category, error) {
+	categories, err := r.GetAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(map[uuid.UUID]bool, len(categories))
+	for _, category := range categories {
+		visible[category.ID] = true
+	}
+
+	roots := []models.Category{}
+	children := map[uuid.UUID][]models.Category{}
+	for _, category := range categories {
+		switch {
+		case category.ParentID == nil:
+			roots = append(roots, category)
+		case visible[*category.ParentID]:
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	return attach(roots), nil
+}
+
+func (r *CategoryRepository) GetCategoryByID(ctx context.Context, id string) (*models.Category, error) {
+	query := `
+		SELECT ` + categoryColumns + `
+		FROM categories
+		WHERE id = $1 AND is_deleted = false
+	`
+
+	return scanCategory(r.DB.QueryRow(ctx, query, id))
+}
+
+// CreateCategory inserts a category under parentID, or at the top level when parentID is nil.
+func (r *CategoryRepository) CreateCategory(ctx context.Context, name, description string, parentID, createdBy *string) (*models.Category, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if parentID != nil {
+		if err := checkCategoryParent(ctx, tx, *parentID, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		INSERT INTO categories (name, description, parent_id, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING ` + categoryColumns
+
+	category, err := scanCategory(tx.QueryRow(ctx, query, name, description, parentID, createdBy))
+	if err != nil {
+		return nil, err
+	}
+
+	return category, tx.Commit(ctx)
+}
+
+// UpdateCategory replaces the name, description and parent of a category. It fails
+// with ErrCategoryCycle when parentID is the category itself or one of its subcategories.
+func (r *CategoryRepository) UpdateCategory(ctx context.Context, id, name, description string, parentID, updatedBy *string) (*models.Category, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if parentID != nil {
+		if err := checkCategoryParent(ctx, tx, *parentID, &id); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		UPDATE categories
+		SET name = $2, description = $3, parent_id = $4, updated_by = $5, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + categoryColumns
+
+	category, err := scanCategory(tx.QueryRow(ctx, query, id, name, description, parentID, updatedBy))
+	if err != nil {
+		return nil, err
+	}
+
+	// Category names are part of the product search document
+	if err := refreshProductSearch(ctx, tx, "p.category_id = $1", id); err != nil {
+		return nil, err
+	}
+
+	return category, tx.Commit(ctx)
+}
+
+// checkCategoryParent verifies that parentID is a category that is not deleted and,
+// when movingID is set, that it is not movingID or one of its subcategories. It locks
+// the categories table so concurrent moves cannot create a cycle together or attach
+// to a category that is being deleted.
+func checkCategoryParent(ctx context.Context, tx pgx.Tx, parentID string, movingID *string) error {
+	if _, err := tx.Exec(ctx, "LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	var parentExists, cycle bool
+	err := tx.QueryRow(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1 AND is_deleted = false
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors), EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+	`, parentID, movingID).Scan(&parentExists, &cycle)
+	if err != nil {
+		return err
+	}
+
+	if !parentExists {
+		return ErrParentCategoryNotFound
+	}
+	if cycle {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// categoryBreadcrumbs returns the path from the top-level category down to categoryID.
+func categoryBreadcrumbs(ctx context.Context, q querier, categoryID string) ([]models.Breadcrumb, error) {
+	breadcrumbs, err := categoriesBreadcrumbs(ctx, q, []string{categoryID})
+	return breadcrumbs[categoryID], err
+}
+
+// categoriesBreadcrumbs returns the breadcrumbs of the given categories in one
+// query, keyed by category ID.
+func categoriesBreadcrumbs(ctx context.Context, q querier, categoryIDs []string) (map[string][]models.Breadcrumb, error) {
+	rows, err := q.Query(ctx, `
+		WITH RECURSIVE path AS (
+			SELECT id AS category_id, id, name, parent_id, 0 AS depth FROM categories WHERE id = ANY($1::uuid[])
+			UNION ALL
+			SELECT p.category_id, c.id, c.name, c.parent_id, p.depth + 1
+			FROM categories c JOIN path p ON c.id = p.parent_id
+			WHERE p.depth < $2
+		)
+		SELECT category_id::text, id, name FROM path ORDER BY category_id, depth DESC
+	`, categoryIDs, maxCategoryDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breadcrumbs := map[string][]models.Breadcrumb{}
+	for rows.Next() {
+		var categoryID string
+		var breadcrumb models.Breadcrumb
+		if err := rows.Scan(&categoryID, &breadcrumb.ID, &breadcrumb.Name); err != nil {
+			return nil, err
+		}
+		breadcrumbs[categoryID] = append(breadcrumbs[categoryID], breadcrumb)
+	}
+
+	return breadcrumbs, rows.Err()
+}
+
+// SoftDelete marks the category as deleted. It fails with ErrCategoryInUse while
+// any product that is not deleted references it, and with ErrCategoryHasChildren
+// while it has subcategories that are not deleted.
+func (r *CategoryRepository) SoftDelete(ctx context.Context, id string, updatedBy *string) (*models.Category, error) {
+	query := `
+		UPDATE categories
+		SET is_deleted = true, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+			AND NOT EXISTS (SELECT 1 FROM products WHERE category_id = $1 AND is_deleted = false)
+			AND NOT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1 AND is_deleted = false)
+		RETURNING ` + categoryColumns
+
+	category, err := scanCategory(r.DB.QueryRow(ctx, query, id, updatedBy))
+	if errors.Is(err, ErrCategoryNotFound) {
+		if _, getErr := r.GetCategoryByID(ctx, id); getErr == nil {
+			var hasChildren bool
+			if err := r.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1 AND is_deleted = false)", id).Scan(&hasChildren); err != nil {
+				return nil, err
+			}
+			if hasChildren {
+				return nil, ErrCategoryHasChildren
+			}
+			return nil, ErrCategoryInUse
+		}
+	}
+	return category, err
+}
+
+// Restore undoes SoftDelete. It fails with ErrCategoryNameTaken when another
+// category took the name in the meantime, and with ErrParentCategoryDeleted while
+// the parent is still deleted.
+func (r *CategoryRepository) Restore(ctx context.Context, id string, updatedBy *string) (*models.Category, error) {
+	query := `
+		UPDATE categories
+		SET is_deleted = false, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = true
+			AND (parent_id IS NULL OR EXISTS (SELECT 1 FROM categories p WHERE p.id = categories.parent_id AND p.is_deleted = false))
+		RETURNING ` + categoryColumns
+
+	category, err := scanCategory(r.DB.QueryRow(ctx, query, id, updatedBy))
+	if errors.Is(err, ErrCategoryNotFound) {
+		var deleted bool
+		if err := r.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND is_deleted = true)", id).Scan(&deleted); err != nil {
+			return nil, err
+		}
+		if deleted {
+			return nil, ErrParentCategoryDeleted
+		}
+	}
+	return category, err
+}
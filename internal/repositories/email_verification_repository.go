@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrVerificationTokenInvalid = errors.New("verification token is invalid or has expired")
+
+type EmailVerificationRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewEmailVerificationRepository(db *pgxpool.Pool) *EmailVerificationRepository {
+	return &EmailVerificationRepository{DB: db}
+}
+
+// CreateVerificationToken stores a token that verifies email for the user and
+// invalidates any earlier unused tokens of the same user.
+func (r *EmailVerificationRepository) CreateVerificationToken(ctx context.Context, userID, email, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+
+	_, err = tx.Exec(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = $2
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, normalizeEmail(email), tokenHash, expiresAt, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetLastTokenCreatedAt returns when the most recent token was issued to the user,
+// or nil if none was.
+func (r *EmailVerificationRepository) GetLastTokenCreatedAt(ctx context.Context, userID string) (*time.Time, error) {
+	var createdAt *time.Time
+	err := r.DB.QueryRow(ctx, `
+		SELECT max(created_at)
+		FROM email_verification_tokens
+		WHERE user_id = $1
+	`, userID).Scan(&createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdAt, nil
+}
+
+// VerifyEmail consumes a token and marks its email as the user's verified email.
+// For email-change tokens this switches the account to the new address. Only the
+// latest token of a user is valid, so an older token cannot revert a change.
+// It returns the ID of the verified user.
+func (r *EmailVerificationRepository) VerifyEmail(ctx context.Context, tokenHash string) (uuid.UUID, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+
+	var userID uuid.UUID
+	var email string
+	err = tx.QueryRow(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id, email
+	`, tokenHash, now).Scan(&userID, &email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrVerificationTokenInvalid
+		}
+		return uuid.Nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET email = $2, email_verified_at = $3, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+	`, userID, email, now)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return uuid.Nil, ErrEmailTaken
+		}
+		return uuid.Nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return uuid.Nil, ErrVerificationTokenInvalid
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTOTPCodeReused = errors.New("totp code was already used")
+
+// MFARepository manages the TOTP columns of users and their recovery codes.
+type MFARepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewMFARepository(db *pgxpool.Pool) *MFARepository {
+	return &MFARepository{DB: db}
+}
+
+// SetPendingTOTPSecret stores a secret that is not enforced until EnableTOTP is called.
+func (r *MFARepository) SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	tag, err := r.DB.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = $2, totp_last_counter = 0
+		WHERE id = $1 AND totp_enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication and replaces the recovery codes
+// in one transaction.
+func (r *MFARepository) EnableTOTP(ctx context.Context, userID string, counter int64, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET totp_enabled_at = $2, totp_last_counter = $3
+		WHERE id = $1 AND totp_secret <> '' AND totp_enabled_at IS NULL
+	`, userID, time.Now().UTC(), counter)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DisableTOTP clears the secret and deletes all recovery codes.
+func (r *MFARepository) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = '', totp_enabled_at = NULL, totp_last_counter = 0
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPCounter records counter as the last accepted time step. It returns
+// ErrTOTPCodeReused if a code from the same or a later step was already accepted.
+func (r *MFARepository) UseTOTPCounter(ctx context.Context, userID string, counter int64) error {
+	tag, err := r.DB.Exec(ctx, `
+		UPDATE users
+		SET totp_last_counter = $2
+		WHERE id = $1 AND totp_last_counter < $2
+	`, userID, counter)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false if the
+// code does not exist or was already used.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	tag, err := r.DB.Exec(ctx, `
+		UPDATE mfa_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReplaceRecoveryCodes invalidates all recovery codes of the user and stores new ones.
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrOAuthStateInvalid = errors.New("oauth state is invalid or has expired")
+	ErrIdentityNotFound  = errors.New("identity not found")
+)
+
+type OAuthRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewOAuthRepository(db *pgxpool.Pool) *OAuthRepository {
+	return &OAuthRepository{DB: db}
+}
+
+// CreateLoginState stores a pending login and removes expired ones.
+func (r *OAuthRepository) CreateLoginState(ctx context.Context, state models.OAuthLoginState) error {
+	if _, err := r.DB.Exec(ctx, "DELETE FROM oauth_login_states WHERE expires_at < $1", time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO oauth_login_states (state_hash, provider, code_verifier, nonce, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.ExpiresAt)
+	return err
+}
+
+// ConsumeLoginState deletes and returns the pending login of provider with
+// stateHash. Each state can be used only once.
+func (r *OAuthRepository) ConsumeLoginState(ctx context.Context, stateHash, provider string) (*models.OAuthLoginState, error) {
+	var state models.OAuthLoginState
+	err := r.DB.QueryRow(ctx, `
+		DELETE FROM oauth_login_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > $3
+		RETURNING state_hash, provider, code_verifier, nonce, expires_at
+	`, stateHash, provider, time.Now().UTC()).Scan(&state.StateHash, &state.Provider, &state.CodeVerifier, &state.Nonce, &state.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthStateInvalid
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// GetUserIDByIdentity returns the user linked to the external account and
+// records the login.
+func (r *OAuthRepository) GetUserIDByIdentity(ctx context.Context, provider, subject string) (string, error) {
+	var userID string
+	err := r.DB.QueryRow(ctx, `
+		UPDATE user_identities
+		SET last_login_at = now()
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id::text
+	`, provider, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrIdentityNotFound
+		}
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// LinkIdentity links an external account to an existing user. The caller checks
+// that the user's email is verified, so the identity cannot be linked to an
+// account someone else registered with that email.
+func (r *OAuthRepository) LinkIdentity(ctx context.Context, userID, provider, subject, email string) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, now())
+	`, userID, provider, subject, normalizeEmail(email))
+	return err
+}
+
+// CreateUserWithIdentity creates a user with a verified email for a new external
+// account. The password is random, so the account can only sign in through the
+// provider until the user resets it.
+func (r *OAuthRepository) CreateUserWithIdentity(ctx context.Context, email, fullName, role, password, provider, subject string) (*models.User, error) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := scanUser(tx.QueryRow(ctx, `
+		INSERT INTO users (email, password, role, full_name, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+userColumns, normalizeEmail(email), hashedPassword, role, fullName, time.Now().UTC()))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, now())
+	`, user.ID, provider, subject, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
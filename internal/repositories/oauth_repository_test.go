@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestConsumeLoginState(t *testing.T) {
+	db := testDB(t)
+	repo := NewOAuthRepository(db)
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// consume is the provider the state is redeemed for, once per entry.
+		consume []string
+		want    []error
+	}{
+		{"pending login", time.Minute, []string{"oidc"}, []error{nil}},
+		{"used twice", time.Minute, []string{"oidc", "oidc"}, []error{nil, ErrOAuthStateInvalid}},
+		{"other provider", time.Minute, []string{"google", "oidc"}, []error{ErrOAuthStateInvalid, nil}},
+		{"expired", -time.Minute, []string{"oidc"}, []error{ErrOAuthStateInvalid}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			state := models.OAuthLoginState{
+				StateHash:    "hash-" + uuid.NewString(),
+				Provider:     "oidc",
+				CodeVerifier: "verifier",
+				Nonce:        "nonce",
+				ExpiresAt:    time.Now().UTC().Add(tt.expiresIn),
+			}
+			if err := repo.CreateLoginState(ctx, state); err != nil {
+				t.Fatalf("CreateLoginState: %v", err)
+			}
+			t.Cleanup(func() { db.Exec(ctx, "DELETE FROM oauth_login_states WHERE state_hash = $1", state.StateHash) })
+
+			for i, provider := range tt.consume {
+				got, err := repo.ConsumeLoginState(ctx, state.StateHash, provider)
+				if !errors.Is(err, tt.want[i]) {
+					t.Fatalf("ConsumeLoginState #%d error = %v, want %v", i+1, err, tt.want[i])
+				}
+				if err == nil && (got.CodeVerifier != state.CodeVerifier || got.Nonce != state.Nonce) {
+					t.Errorf("ConsumeLoginState = %+v, want the verifier and nonce of %+v", got, state)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrOptionTypeNotFound  = errors.New("option type not found")
+	ErrOptionTypeNameTaken = errors.New("an option type with this name already exists")
+	ErrOptionTypeBuiltIn   = errors.New("size and color are built-in option types")
+	ErrUnknownOptionType   = errors.New("option type is not offered by the product's category")
+)
+
+// optionTypeColumns is the column list read by scanOptionType.
+const optionTypeColumns = `id, name, lower(name) IN ('size', 'color'), created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+// isBuiltInOption reports whether name is an option type stored on the variant itself.
+func isBuiltInOption(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return name == "size" || name == "color"
+}
+
+type OptionTypeRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewOptionTypeRepository(db *pgxpool.Pool) *OptionTypeRepository {
+	return &OptionTypeRepository{DB: db}
+}
+
+// scanOptionType reads a row selected with optionTypeColumns.
+func scanOptionType(row pgx.Row) (*models.OptionType, error) {
+	var optionType models.OptionType
+	err := row.Scan(&optionType.ID, &optionType.Name, &optionType.BuiltIn,
+		&optionType.CreatedBy, &optionType.CreatedAt, &optionType.UpdatedBy, &optionType.UpdatedAt, &optionType.IsActive, &optionType.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOptionTypeNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrOptionTypeNameTaken
+		}
+		return nil, err
+	}
+	return &optionType, nil
+}
+
+func collectOptionTypes(rows pgx.Rows) ([]models.OptionType, error) {
+	defer rows.Close()
+
+	optionTypes := []models.OptionType{}
+	for rows.Next() {
+		optionType, err := scanOptionType(rows)
+		if err != nil {
+			return nil, err
+		}
+		optionTypes = append(optionTypes, *optionType)
+	}
+
+	return optionTypes, rows.Err()
+}
+
+func (r *OptionTypeRepository) GetAllOptionTypes(ctx context.Context) ([]models.OptionType, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT `+optionTypeColumns+`
+		FROM option_types
+		WHERE is_deleted = false
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectOptionTypes(rows)
+}
+
+func (r *OptionTypeRepository) CreateOptionType(ctx context.Context, name string, createdBy *string) (*models.OptionType, error) {
+	query := `
+		INSERT INTO option_types (name, created_by, updated_by)
+		VALUES ($1, $2, $2)
+		RETURNING ` + optionTypeColumns
+
+	return scanOptionType(r.DB.QueryRow(ctx, query, name, createdBy))
+}
+
+// UpdateOptionType renames an option type. Size and Color cannot be renamed.
+func (r *OptionTypeRepository) UpdateOptionType(ctx context.Context, id, name string, updatedBy *string) (*models.OptionType, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE option_types
+		SET name = $2, updated_by = $3, updated_at = now()
+		WHERE id = $1 AND is_deleted = false AND lower(name) NOT IN ('size', 'color')
+		RETURNING ` + optionTypeColumns
+
+	optionType, err := scanOptionType(tx.QueryRow(ctx, query, id, name, updatedBy))
+	if errors.Is(err, ErrOptionTypeNotFound) {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM option_types WHERE id = $1 AND is_deleted = false)", id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		// The option type exists, so the update was skipped because it is built in
+		if exists {
+			return nil, ErrOptionTypeBuiltIn
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The options keys of variants contain the option type name
+	err = refreshOptionsKeys(ctx, tx, "v.id IN (SELECT variant_id FROM variant_option_values WHERE option_type_id = $1)", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return optionType, tx.Commit(ctx)
+}
+
+// GetCategoryOptionTypes returns the option types offered by a category: those
+// inherited from its parent categories first, then its own, each in position order.
+func (r *OptionTypeRepository) GetCategoryOptionTypes(ctx context.Context, categoryID string) ([]models.OptionType, error) {
+	var exists bool
+	err := r.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1 AND is_deleted = false)", categoryID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrCategoryNotFound
+	}
+
+	rows, err := r.DB.Query(ctx, `
+		WITH RECURSIVE path AS (
+			SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, p.depth + 1
+			FROM categories c JOIN path p ON c.id = p.parent_id
+			WHERE p.depth < $2
+		)
+		SELECT `+optionTypeColumns+`
+		FROM option_types
+		JOIN (
+			SELECT DISTINCT ON (cot.option_type_id) cot.option_type_id, p.depth, cot.position
+			FROM path p
+			JOIN category_option_types cot ON cot.category_id = p.id
+			ORDER BY cot.option_type_id, p.depth DESC
+		) offered ON offered.option_type_id = option_types.id
+		WHERE is_deleted = false
+		ORDER BY offered.depth DESC, offered.position, name
+	`, categoryID, maxCategoryDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectOptionTypes(rows)
+}
+
+// SetCategoryOptionTypes replaces the option types a category offers itself, in
+// the order given. Option types inherited from parent categories are not affected.
+func (r *OptionTypeRepository) SetCategoryOptionTypes(ctx context.Context, categoryID string, optionTypeIDs []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	err = tx.QueryRow(ctx, "SELECT id FROM categories WHERE id = $1 AND is_deleted = false FOR UPDATE", categoryID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM category_option_types WHERE category_id = $1", categoryID); err != nil {
+		return err
+	}
+
+	for position, optionTypeID := range optionTypeIDs {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO category_option_types (category_id, option_type_id, position)
+			SELECT $1, id, $3 FROM option_types WHERE id = $2 AND is_deleted = false
+			ON CONFLICT (category_id, option_type_id) DO NOTHING
+		`, categoryID, optionTypeID, position)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			var duplicate bool
+			err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM category_option_types WHERE category_id = $1 AND option_type_id = $2)", categoryID, optionTypeID).Scan(&duplicate)
+			if err != nil {
+				return err
+			}
+			if !duplicate {
+				return ErrOptionTypeNotFound
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
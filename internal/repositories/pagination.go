@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for a cursor that Cursor.String did not produce.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sqlArgs collects query parameters; add returns the placeholder of the new one.
+type sqlArgs []any
+
+func (a *sqlArgs) add(value any) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// Cursor is the position of a row in a listing ordered newest first, by
+// created_at and then id. The page after it starts with the next older row.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.CreatedAt.UTC().Format(time.RFC3339Nano) + "_" + c.ID.String()))
+}
+
+// ParseCursor decodes a token produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// Pagination selects a page of a listing by its number, or the rows after a
+// cursor when After is set. Cursor pages do not slow down the deeper they are.
+type Pagination struct {
+	Page  int
+	Limit int
+	After *Cursor
+}
+
+// keyset returns the condition that skips the rows up to the cursor, or "" for
+// numbered pages. The columns are qualified with prefix, e.g. "p.".
+func (p Pagination) keyset(args *sqlArgs, prefix string) string {
+	if p.After == nil {
+		return ""
+	}
+	return ` AND (` + prefix + `created_at, ` + prefix + `id) < (` + args.add(p.After.CreatedAt) + `, ` + args.add(p.After.ID) + `)`
+}
+
+// limit returns the LIMIT and OFFSET of the page. It selects one row more than
+// the page holds, so newPage can tell whether another page follows.
+func (p Pagination) limit(args *sqlArgs) string {
+	offset := 0
+	if p.After == nil {
+		offset = (p.Page - 1) * p.Limit
+	}
+	return ` LIMIT ` + args.add(p.Limit+1) + ` OFFSET ` + args.add(offset)
+}
+
+// Page is one page of a listing.
+type Page[T any] struct {
+	Items []T
+	// Total is the number of rows on all pages.
+	Total int
+	// Next is the cursor of the following page, nil on the last page.
+	Next *Cursor
+}
+
+// newPage trims the extra row selected by Pagination.limit and sets Next from
+// the last row of the page. A nil cursor leaves Next unset.
+func newPage[T any](items []T, total int, p Pagination, cursor func(T) Cursor) Page[T] {
+	page := Page[T]{Items: items, Total: total}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(page.Items) > p.Limit {
+		page.Items = page.Items[:p.Limit]
+		if cursor != nil {
+			next := cursor(page.Items[p.Limit-1])
+			page.Next = &next
+		}
+	}
+	return page
+}
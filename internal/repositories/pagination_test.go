@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC), ID: uuid.New()},
+		{CreatedAt: time.Date(2024, 5, 1, 19, 30, 0, 0, time.FixedZone("ICT", 7*60*60)), ID: uuid.New()},
+		{CreatedAt: time.Unix(0, 0), ID: uuid.Nil},
+	}
+
+	for _, cursor := range tests {
+		got, err := ParseCursor(cursor.String())
+		if err != nil {
+			t.Fatalf("ParseCursor(%q): %v", cursor.String(), err)
+		}
+		if !got.CreatedAt.Equal(cursor.CreatedAt) || got.ID != cursor.ID {
+			t.Errorf("ParseCursor(%q) = %v, want %v", cursor.String(), got, cursor)
+		}
+	}
+}
+
+func TestParseCursorRejectsTamperedTokens(t *testing.T) {
+	valid := Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), ID: uuid.New()}.String()
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "!!" + valid},
+		{"padded base64", valid + "=="},
+		{"truncated", valid[:len(valid)-4]},
+		{"no separator", encode("2024-05-01T12:30:00Z")},
+		{"bad time", encode("yesterday_" + uuid.NewString())},
+		{"bad id", encode("2024-05-01T12:30:00Z_42")},
+		{"sql in id", encode("2024-05-01T12:30:00Z_' OR 1=1 --")},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCursor(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("ParseCursor(%q) error = %v, want ErrInvalidCursor", tt.token, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrResetTokenInvalid = errors.New("reset token is invalid or has expired")
+
+type PasswordResetRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPasswordResetRepository(db *pgxpool.Pool) *PasswordResetRepository {
+	return &PasswordResetRepository{DB: db}
+}
+
+// CreateResetToken stores a new reset token hash and invalidates any earlier
+// unused tokens of the same user.
+func (r *PasswordResetRepository) CreateResetToken(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = now()
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, tokenHash, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ResetPassword consumes a reset token and sets the new password of its owner in
+// one transaction. It returns the ID of the user whose password was changed, and
+// ErrResetTokenInvalid when the owner is no longer active.
+func (r *PasswordResetRepository) ResetPassword(ctx context.Context, tokenHash, newPassword string) (uuid.UUID, error) {
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id
+	`, tokenHash, time.Now().UTC()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrResetTokenInvalid
+		}
+		return uuid.Nil, err
+	}
+
+	// A token issued before the account was deactivated or deleted is not redeemable
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET password = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false AND is_active = true
+	`, userID, hashedPassword)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return uuid.Nil, ErrResetTokenInvalid
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
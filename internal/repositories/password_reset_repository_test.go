@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestResetPassword(t *testing.T) {
+	db := testDB(t)
+	users := NewUserRepository(db)
+	repo := NewPasswordResetRepository(db)
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		// prepare changes the account after the token is issued.
+		prepare func(ctx context.Context, userID string) error
+		// reuse redeems the token a second time.
+		reuse   bool
+		wantErr error
+	}{
+		{name: "valid token", expiresIn: time.Hour},
+		{name: "expired token", expiresIn: -time.Minute, wantErr: ErrResetTokenInvalid},
+		{name: "used token", expiresIn: time.Hour, reuse: true, wantErr: ErrResetTokenInvalid},
+		{
+			name:      "deactivated account",
+			expiresIn: time.Hour,
+			prepare: func(ctx context.Context, userID string) error {
+				_, err := users.ToggleActive(ctx, userID, nil)
+				return err
+			},
+			wantErr: ErrResetTokenInvalid,
+		},
+		{
+			name:      "deleted account",
+			expiresIn: time.Hour,
+			prepare: func(ctx context.Context, userID string) error {
+				_, err := users.SoftDelete(ctx, userID, nil)
+				return err
+			},
+			wantErr: ErrResetTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			user := createTestUser(t, users)
+			tokenHash := "hash-" + uuid.NewString()
+			if err := repo.CreateResetToken(ctx, user.ID.String(), tokenHash, time.Now().UTC().Add(tt.expiresIn)); err != nil {
+				t.Fatalf("CreateResetToken: %v", err)
+			}
+			if tt.prepare != nil {
+				if err := tt.prepare(ctx, user.ID.String()); err != nil {
+					t.Fatalf("prepare: %v", err)
+				}
+			}
+			if tt.reuse {
+				if _, err := repo.ResetPassword(ctx, tokenHash, "first-password"); err != nil {
+					t.Fatalf("ResetPassword: %v", err)
+				}
+			}
+
+			userID, err := repo.ResetPassword(ctx, tokenHash, "new-password")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword error = %v, want %v", err, tt.wantErr)
+			}
+
+			var hashed string
+			var updatedAt *time.Time
+			if err := db.QueryRow(ctx, "SELECT password, updated_at FROM users WHERE id = $1", user.ID).Scan(&hashed, &updatedAt); err != nil {
+				t.Fatalf("read user: %v", err)
+			}
+			if changed := users.CheckPassword(hashed, "new-password"); changed != (err == nil) {
+				t.Errorf("password changed = %t, want %t", changed, err == nil)
+			}
+			if err == nil && (userID != user.ID || updatedAt == nil || !updatedAt.After(user.UpdatedAt)) {
+				t.Errorf("ResetPassword = %s with updated_at %v, want %s updated now", userID, updatedAt, user.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ProductFilter narrows GetAllProducts and GetProductFacets; empty fields are not
+// filtered on. Several values of one field match products with any of them.
+type ProductFilter struct {
+	MinPrice *float64
+	MaxPrice *float64
+	// PriceRanges matches products whose lowest price is in one of the ranges.
+	PriceRanges []PriceRange
+	// Categories are category IDs or names. Products in their subcategories match
+	// as well unless ExcludeSubcategories is set.
+	Categories           []string
+	ExcludeSubcategories bool
+	// Brands are brand IDs or names, matched exactly but not case-sensitively.
+	Brands []string
+	// Sizes and Colors match products with an active variant in stock in one of them.
+	Sizes  []string
+	Colors []string
+	Search *string
+	// Attributes matches products that have every attribute with the given value.
+	Attributes map[string]string
+	// Options matches products with at least one active variant that has every
+	// option value, keyed by option type name. Size and color are included.
+	Options map[string]string
+}
+
+// PriceRange is a range of product prices from Min up to, but not including, Max.
+// A nil Max has no upper bound.
+type PriceRange struct {
+	Min float64
+	Max *float64
+}
+
+// ParsePriceRange parses a range written as "25-50", or "200-" without an upper bound.
+func ParsePriceRange(s string) (PriceRange, bool) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return PriceRange{}, false
+	}
+
+	minPrice, err := strconv.ParseFloat(strings.TrimSpace(from), 64)
+	if err != nil || minPrice < 0 {
+		return PriceRange{}, false
+	}
+	if to = strings.TrimSpace(to); to == "" {
+		return PriceRange{Min: minPrice}, true
+	}
+
+	maxPrice, err := strconv.ParseFloat(to, 64)
+	if err != nil || maxPrice <= minPrice {
+		return PriceRange{}, false
+	}
+	return PriceRange{Min: minPrice, Max: &maxPrice}, true
+}
+
+// String formats the range the way ParsePriceRange reads it.
+func (p PriceRange) String() string {
+	s := strconv.FormatFloat(p.Min, 'f', -1, 64) + "-"
+	if p.Max != nil {
+		s += strconv.FormatFloat(*p.Max, 'f', -1, 64)
+	}
+	return s
+}
+
+// condition returns the SQL condition matching products in the range.
+func (p PriceRange) condition(args *sqlArgs) string {
+	condition := `p.min_price >= ` + args.add(p.Min)
+	if p.Max != nil {
+		condition += ` AND p.min_price < ` + args.add(*p.Max)
+	}
+	return condition
+}
+
+// Facets a product condition can belong to.
+const (
+	facetCategory = "category"
+	facetBrand    = "brand"
+	facetSize     = "size"
+	facetColor    = "color"
+	facetPrice    = "price"
+)
+
+// productCondition is one condition of a product listing. Facet counts leave out
+// the conditions of their own facet, so other values stay visible once one is selected.
+type productCondition struct {
+	// facet is the facet the condition filters on, or "" when it always applies.
+	facet string
+	sql   func(args *sqlArgs) string
+}
+
+// productWhere joins the conditions that do not belong to skipFacet. The query
+// selects from products p LEFT JOIN brands b.
+func productWhere(conditions []productCondition, skipFacet string, args *sqlArgs) string {
+	where := `p.is_active = true AND p.is_deleted = false`
+	for _, condition := range conditions {
+		if skipFacet != "" && condition.facet == skipFacet {
+			continue
+		}
+		where += ` AND ` + condition.sql(args)
+	}
+	return where
+}
+
+// lowerAll returns the values in lower case.
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return lowered
+}
+
+// productConditions translates filter into conditions.
+func productConditions(filter ProductFilter) []productCondition {
+	var conditions []productCondition
+	add := func(facet string, sql func(args *sqlArgs) string) {
+		conditions = append(conditions, productCondition{facet: facet, sql: sql})
+	}
+
+	if filter.Search != nil {
+		add("", func(args *sqlArgs) string {
+			return `p.search_vector @@ websearch_to_tsquery('` + searchConfig + `', ` + args.add(*filter.Search) + `)`
+		})
+	}
+
+	if filter.MinPrice != nil {
+		add(facetPrice, func(args *sqlArgs) string { return `p.min_price >= ` + args.add(*filter.MinPrice) })
+	}
+
+	if filter.MaxPrice != nil {
+		add(facetPrice, func(args *sqlArgs) string { return `p.max_price <= ` + args.add(*filter.MaxPrice) })
+	}
+
+	if len(filter.PriceRanges) > 0 {
+		add(facetPrice, func(args *sqlArgs) string {
+			ranges := make([]string, len(filter.PriceRanges))
+			for i, priceRange := range filter.PriceRanges {
+				ranges[i] = `(` + priceRange.condition(args) + `)`
+			}
+			return `(` + strings.Join(ranges, ` OR `) + `)`
+		})
+	}
+
+	if len(filter.Categories) > 0 {
+		add(facetCategory, func(args *sqlArgs) string {
+			arg := args.add(lowerAll(filter.Categories))
+			match := `(id::text = ANY(` + arg + `) OR lower(name) = ANY(` + arg + `)) AND is_deleted = false`
+			if filter.ExcludeSubcategories {
+				return `p.category_id IN (SELECT id FROM categories WHERE ` + match + `)`
+			}
+			return `p.category_id IN (
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM categories WHERE ` + match + `
+					UNION
+					SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id WHERE c.is_deleted = false
+				)
+				SELECT id FROM subtree
+			)`
+		})
+	}
+
+	if len(filter.Brands) > 0 {
+		add(facetBrand, func(args *sqlArgs) string {
+			arg := args.add(lowerAll(filter.Brands))
+			return `(b.id::text = ANY(` + arg + `) OR lower(b.name) = ANY(` + arg + `))`
+		})
+	}
+
+	if len(filter.Sizes) > 0 {
+		add(facetSize, func(args *sqlArgs) string {
+			return `EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.is_active = true AND v.is_deleted = false AND v.stock > 0
+				AND lower(v.size) = ANY(` + args.add(lowerAll(filter.Sizes)) + `))`
+		})
+	}
+
+	if len(filter.Colors) > 0 {
+		add(facetColor, func(args *sqlArgs) string {
+			return `EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.is_active = true AND v.is_deleted = false AND v.stock > 0
+				AND lower(v.color) = ANY(` + args.add(lowerAll(filter.Colors)) + `))`
+		})
+	}
+
+	for _, name := range sortedKeys(filter.Attributes) {
+		add("", func(args *sqlArgs) string {
+			return `EXISTS (SELECT 1 FROM product_attributes pa WHERE pa.product_id = p.id
+				AND lower(pa.name) = lower(` + args.add(name) + `) AND lower(pa.value) = lower(` + args.add(filter.Attributes[name]) + `))`
+		})
+	}
+
+	if len(filter.Options) > 0 {
+		add("", func(args *sqlArgs) string {
+			sql := `EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.is_active = true AND v.is_deleted = false`
+			for _, name := range sortedKeys(filter.Options) {
+				value := `lower(` + args.add(filter.Options[name]) + `)`
+				switch strings.ToLower(strings.TrimSpace(name)) {
+				case "size":
+					sql += ` AND lower(v.size) = ` + value
+				case "color":
+					sql += ` AND lower(v.color) = ` + value
+				default:
+					sql += ` AND EXISTS (
+						SELECT 1 FROM variant_option_values vov JOIN option_types ot ON ot.id = vov.option_type_id
+						WHERE vov.variant_id = v.id AND lower(vov.value) = ` + value + ` AND lower(ot.name) = lower(` + args.add(name) + `))`
+				}
+			}
+			return sql + `)`
+		})
+	}
+
+	return conditions
+}
+
+// GetProductFacets counts the active products per category, brand, size in stock,
+// color in stock and price range. The counts of each facet apply every filter
+// except the ones on that facet.
+func (r *ProductRepository) GetProductFacets(ctx context.Context, filter ProductFilter) (*models.ProductFacets, error) {
+	conditions := productConditions(filter)
+	categories, brands := lowerAll(filter.Categories), lowerAll(filter.Brands)
+	sizes, colors := lowerAll(filter.Sizes), lowerAll(filter.Colors)
+
+	facets := &models.ProductFacets{PriceRanges: []models.PriceRangeFacet{}}
+	var err error
+
+	args := sqlArgs{}
+	facets.Categories, err = r.facetValues(ctx, `
+		SELECT c.id, c.name, count(*)
+		FROM products p
+		LEFT JOIN brands b ON p.brand_id = b.id
+		JOIN categories c ON c.id = p.category_id
+		WHERE `+productWhere(conditions, facetCategory, &args)+`
+		GROUP BY c.id, c.name
+		ORDER BY count(*) DESC, c.name
+	`, args, func(id *uuid.UUID, value string) bool {
+		return slices.Contains(categories, id.String()) || slices.Contains(categories, strings.ToLower(value))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	args = sqlArgs{}
+	facets.Brands, err = r.facetValues(ctx, `
+		SELECT b.id, b.name, count(*)
+		FROM products p
+		JOIN brands b ON p.brand_id = b.id
+		WHERE `+productWhere(conditions, facetBrand, &args)+`
+		GROUP BY b.id, b.name
+		ORDER BY count(*) DESC, b.name
+	`, args, func(id *uuid.UUID, value string) bool {
+		return slices.Contains(brands, id.String()) || slices.Contains(brands, strings.ToLower(value))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, facet := range []struct {
+		name     string
+		column   string
+		selected []string
+		values   *[]models.FacetValue
+	}{
+		{facetSize, "size", sizes, &facets.Sizes},
+		{facetColor, "color", colors, &facets.Colors},
+	} {
+		args = sqlArgs{}
+		*facet.values, err = r.facetValues(ctx, `
+			SELECT NULL::uuid, min(v.`+facet.column+`), count(DISTINCT p.id)
+			FROM products p
+			LEFT JOIN brands b ON p.brand_id = b.id
+			JOIN product_variants v ON v.product_id = p.id AND v.is_active = true AND v.is_deleted = false AND v.stock > 0
+			WHERE `+productWhere(conditions, facet.name, &args)+`
+			GROUP BY lower(v.`+facet.column+`)
+			ORDER BY count(DISTINCT p.id) DESC, 2
+		`, args, func(id *uuid.UUID, value string) bool {
+			return slices.Contains(facet.selected, strings.ToLower(value))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(r.PriceBuckets) == 0 {
+		return facets, nil
+	}
+
+	args = sqlArgs{}
+	counts := make([]string, len(r.PriceBuckets))
+	for i, bucket := range r.PriceBuckets {
+		counts[i] = `count(*) FILTER (WHERE ` + bucket.condition(&args) + `)`
+	}
+	query := `
+		SELECT ` + strings.Join(counts, ", ") + `
+		FROM products p
+		LEFT JOIN brands b ON p.brand_id = b.id
+		WHERE ` + productWhere(conditions, facetPrice, &args)
+
+	facets.PriceRanges = make([]models.PriceRangeFacet, len(r.PriceBuckets))
+	dest := make([]any, len(r.PriceBuckets))
+	for i, bucket := range r.PriceBuckets {
+		facets.PriceRanges[i] = models.PriceRangeFacet{Key: bucket.String(), Min: bucket.Min, Max: bucket.Max}
+		for _, selected := range filter.PriceRanges {
+			if selected.String() == bucket.String() {
+				facets.PriceRanges[i].Selected = true
+			}
+		}
+		dest[i] = &facets.PriceRanges[i].Count
+	}
+	if err := r.DB.QueryRow(ctx, query, args...).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// facetValues runs a facet query selecting an optional ID, a value and a count.
+func (r *ProductRepository) facetValues(ctx context.Context, query string, args sqlArgs, selected func(id *uuid.UUID, value string) bool) ([]models.FacetValue, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []models.FacetValue{}
+	for rows.Next() {
+		var value models.FacetValue
+		if err := rows.Scan(&value.ID, &value.Value, &value.Count); err != nil {
+			return nil, err
+		}
+		value.Selected = selected(value.ID, value.Value)
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParsePriceRange(t *testing.T) {
+	tests := []struct {
+		in     string
+		ok     bool
+		min    float64
+		max    float64
+		hasMax bool
+	}{
+		{"25-50", true, 25, 50, true},
+		{" 0 - 9.99 ", true, 0, 9.99, true},
+		{"200-", true, 200, 0, false},
+		{"200", false, 0, 0, false},
+		{"-50", false, 0, 0, false},
+		{"50-25", false, 0, 0, false},
+		{"50-50", false, 0, 0, false},
+		{"abc-10", false, 0, 0, false},
+		{"10-abc", false, 0, 0, false},
+		{"", false, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParsePriceRange(tt.in)
+		if ok != tt.ok {
+			t.Errorf("ParsePriceRange(%q) ok = %t, want %t", tt.in, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got.Min != tt.min || (got.Max != nil) != tt.hasMax || (got.Max != nil && *got.Max != tt.max) {
+			t.Errorf("ParsePriceRange(%q) = %s, want min %v, max %v", tt.in, got, tt.min, tt.max)
+		}
+
+		// String writes the range back in a form ParsePriceRange reads.
+		again, ok := ParsePriceRange(got.String())
+		if !ok || again.String() != got.String() {
+			t.Errorf("ParsePriceRange(%q) does not round-trip through String, got %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestBrandFilterMatchesExactly(t *testing.T) {
+	db := testDB(t)
+	repo := NewProductRepository(db, "", nil)
+	ctx := context.Background()
+
+	brand := "Brand 50%_" + uuid.NewString()
+	var brandID string
+	if err := db.QueryRow(ctx, "INSERT INTO brands (name) VALUES ($1) RETURNING id::text", brand).Scan(&brandID); err != nil {
+		t.Fatalf("create brand: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(ctx, "DELETE FROM brands WHERE id = $1", brandID) })
+
+	product := createTestProduct(t, repo, nil)
+	if _, err := db.Exec(ctx, "UPDATE products SET brand_id = $2 WHERE id = $1", product.ID, brandID); err != nil {
+		t.Fatalf("set brand: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		brand string
+		want  bool
+	}{
+		{"name", brand, true},
+		{"name in another case", " " + strings.ToUpper(brand) + " ", true},
+		{"ID", brandID, true},
+		{"part of the name", brand[:len(brand)-4], false},
+		{"wildcards", "Brand 50%", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := ProductFilter{Categories: []string{product.Name}, Brands: []string{tt.brand}}
+			page, err := repo.GetAllProducts(ctx, Pagination{Page: 1, Limit: 10}, filter, nil)
+			if err != nil {
+				t.Fatalf("GetAllProducts: %v", err)
+			}
+			if got := page.Total == 1; got != tt.want {
+				t.Errorf("product matched = %t, want %t", got, tt.want)
+			}
+
+			facets, err := repo.GetProductFacets(ctx, filter)
+			if err != nil {
+				t.Fatalf("GetProductFacets: %v", err)
+			}
+			if len(facets.Brands) != 1 || facets.Brands[0].Selected != tt.want {
+				t.Errorf("brand facets = %+v, want the brand selected = %t", facets.Brands, tt.want)
+			}
+		})
+	}
+}
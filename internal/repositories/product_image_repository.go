@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrImageNotFound = errors.New("product image not found")
+	// ErrImageOrderMismatch is returned when a new gallery order does not list
+	// every image of the gallery exactly once.
+	ErrImageOrderMismatch = errors.New("the new order must list every image of the gallery exactly once")
+)
+
+// productImageColumns is the column list read by scanProductImage.
+const productImageColumns = `id, product_id, variant_id, url, thumbnail_url, storage_key, thumbnail_key, content_type, size_bytes, width, height, alt_text, position, created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+// ProductImageInput holds an uploaded image whose files are already stored.
+type ProductImageInput struct {
+	ID           string
+	VariantID    *string
+	StorageKey   string
+	ThumbnailKey string
+	URL          string
+	ThumbnailURL string
+	ContentType  string
+	SizeBytes    int64
+	Width        int
+	Height       int
+	AltText      string
+}
+
+type ProductImageRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewProductImageRepository(db *pgxpool.Pool) *ProductImageRepository {
+	return &ProductImageRepository{DB: db}
+}
+
+// scanProductImage reads a row selected with productImageColumns.
+func scanProductImage(row pgx.Row) (*models.ProductImage, error) {
+	var image models.ProductImage
+	err := row.Scan(&image.ID, &image.ProductID, &image.VariantID, &image.URL, &image.ThumbnailURL, &image.StorageKey, &image.ThumbnailKey,
+		&image.ContentType, &image.SizeBytes, &image.Width, &image.Height, &image.AltText, &image.Position,
+		&image.CreatedBy, &image.CreatedAt, &image.UpdatedBy, &image.UpdatedAt, &image.IsActive, &image.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrImageNotFound
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
+// productImages returns the images of a product, the product gallery first and
+// then the gallery of each variant that is not deleted, each in position order.
+func productImages(ctx context.Context, q querier, productID string) ([]models.ProductImage, error) {
+	rows, err := q.Query(ctx, `
+		SELECT `+productImageColumns+`
+		FROM product_images i
+		WHERE product_id = $1 AND is_deleted = false
+			AND (variant_id IS NULL OR EXISTS (SELECT 1 FROM product_variants v WHERE v.id = i.variant_id AND v.is_deleted = false))
+		ORDER BY variant_id NULLS FIRST, position, created_at
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []models.ProductImage{}
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, *image)
+	}
+
+	return images, rows.Err()
+}
+
+// GetProductImages returns the images of an active product, see productImages.
+func (r *ProductImageRepository) GetProductImages(ctx context.Context, productID string) ([]models.ProductImage, error) {
+	var exists bool
+	err := r.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND is_active = true AND is_deleted = false)", productID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+
+	return productImages(ctx, r.DB, productID)
+}
+
+// checkImageVariant checks that variantID is a variant of productID that is not deleted.
+func checkImageVariant(ctx context.Context, q querier, productID string, variantID *string) error {
+	if variantID == nil {
+		return nil
+	}
+
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM product_variants WHERE id = $1 AND product_id = $2 AND is_deleted = false)", *variantID, productID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrVariantNotFound
+	}
+	return nil
+}
+
+// CreateProductImage adds an image at the end of the gallery of the product, or of
+// the variant when input.VariantID is set.
+func (r *ProductImageRepository) CreateProductImage(ctx context.Context, productID string, input ProductImageInput, createdBy *string) (*models.ProductImage, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The product lock serializes position assignment within its galleries
+	if err := lockProduct(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+	if err := checkImageVariant(ctx, tx, productID, input.VariantID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO product_images (id, product_id, variant_id, storage_key, thumbnail_key, url, thumbnail_url, content_type, size_bytes, width, height, alt_text, position, created_by, updated_by)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE(max(position) + 1, 0), $13, $13
+		FROM product_images
+		WHERE product_id = $2 AND variant_id IS NOT DISTINCT FROM $3 AND is_deleted = false
+		RETURNING ` + productImageColumns
+
+	image, err := scanProductImage(tx.QueryRow(ctx, query, input.ID, productID, input.VariantID, input.StorageKey, input.ThumbnailKey,
+		input.URL, input.ThumbnailURL, input.ContentType, input.SizeBytes, input.Width, input.Height, input.AltText, createdBy))
+	if err != nil {
+		return nil, err
+	}
+
+	return image, tx.Commit(ctx)
+}
+
+// ReorderProductImages sets the order of the gallery of the product, or of the
+// variant when variantID is set. imageIDs must list every image of the gallery.
+func (r *ProductImageRepository) ReorderProductImages(ctx context.Context, productID string, variantID *string, imageIDs []string, updatedBy *string) ([]models.ProductImage, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProduct(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+	if err := checkImageVariant(ctx, tx, productID, variantID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id::text FROM product_images
+		WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2 AND is_deleted = false
+	`, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	gallery := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		gallery[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(imageIDs) != len(gallery) {
+		return nil, ErrImageOrderMismatch
+	}
+	ordered := make([]string, len(imageIDs))
+	listed := map[string]bool{}
+	for i, id := range imageIDs {
+		id = strings.ToLower(id)
+		if !gallery[id] || listed[id] {
+			return nil, ErrImageOrderMismatch
+		}
+		listed[id] = true
+		ordered[i] = id
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE product_images i
+		SET position = o.position - 1, updated_by = $2, updated_at = now()
+		FROM unnest($1::uuid[]) WITH ORDINALITY AS o(id, position)
+		WHERE i.id = o.id AND i.position IS DISTINCT FROM o.position - 1
+	`, ordered, updatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := productImages(ctx, tx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return images, tx.Commit(ctx)
+}
+
+// SoftDeleteProductImage removes an image of productID from its gallery. The
+// stored files are kept, like the rows of other soft-deleted records.
+func (r *ProductImageRepository) SoftDeleteProductImage(ctx context.Context, productID, imageID string, updatedBy *string) (*models.ProductImage, error) {
+	query := `
+		UPDATE product_images
+		SET is_deleted = true, updated_by = $3, updated_at = now()
+		WHERE id = $1 AND product_id = $2 AND is_deleted = false
+		RETURNING ` + productImageColumns
+
+	return scanProductImage(r.DB.QueryRow(ctx, query, imageID, productID, updatedBy))
+}
@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"sort"
+	"strings"
+)
+
+// optionsKeySeparator separates the option values in product_variants.options_key.
+const optionsKeySeparator = "\x1f"
+
+// variantOptionsKey identifies a variant of a product by its size, color and
+// other option values. It matches the options_key that refreshVariantOptionsKey
+// stores: names are sorted by byte, as SQL sorts them under the "C" collation.
+func variantOptionsKey(size, color string, options map[string]string) string {
+	key := strings.ToLower(strings.TrimSpace(size)) + optionsKeySeparator + strings.ToLower(strings.TrimSpace(color))
+
+	names := make([]string, 0, len(options))
+	values := make(map[string]string, len(options))
+	for name, value := range options {
+		name = strings.ToLower(strings.TrimSpace(name))
+		names = append(names, name)
+		values[name] = strings.ToLower(strings.TrimSpace(value))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key += optionsKeySeparator + name + "=" + values[name]
+	}
+	return key
+}
+
+// refreshVariantOptionsKey recomputes the options_key of a variant after its size,
+// color or option values change. The unique index on options_key rejects a second
+// variant of the same product with the same options.
+func refreshVariantOptionsKey(ctx context.Context, q querier, variantID string) error {
+	return refreshOptionsKeys(ctx, q, "v.id = $1", variantID)
+}
+
+// refreshOptionsKeys recomputes the options_key of the variants v matching where,
+// which takes a single argument.
+func refreshOptionsKeys(ctx context.Context, q querier, where string, arg any) error {
+	_, err := q.Exec(ctx, `
+		UPDATE product_variants v
+		SET options_key = lower(btrim(COALESCE(v.size, ''))) || chr(31) || lower(btrim(COALESCE(v.color, ''))) || COALESCE((
+			SELECT string_agg(chr(31) || lower(btrim(ot.name)) || '=' || lower(btrim(vov.value)), '' ORDER BY lower(btrim(ot.name)) COLLATE "C")
+			FROM variant_option_values vov
+			JOIN option_types ot ON ot.id = vov.option_type_id
+			WHERE vov.variant_id = v.id
+		), '')
+		WHERE `+where, arg)
+	return variantUniqueError(err)
+}
+
+// setVariantOptions replaces the option values of a variant. Every option type
+// must be offered by the category of the product or one of its parent categories;
+// size and color are set on the variant itself.
+func setVariantOptions(ctx context.Context, q querier, productID, variantID string, options map[string]string) error {
+	if _, err := q.Exec(ctx, "DELETE FROM variant_option_values WHERE variant_id = $1", variantID); err != nil {
+		return err
+	}
+
+	for name, value := range options {
+		if isBuiltInOption(name) {
+			return ErrUnknownOptionType
+		}
+
+		tag, err := q.Exec(ctx, `
+			WITH RECURSIVE path AS (
+				SELECT c.id, c.parent_id, 0 AS depth
+				FROM products p JOIN categories c ON c.id = p.category_id
+				WHERE p.id = $1
+				UNION ALL
+				SELECT c.id, c.parent_id, path.depth + 1
+				FROM categories c JOIN path ON c.id = path.parent_id
+				WHERE path.depth < $5
+			)
+			INSERT INTO variant_option_values (variant_id, option_type_id, value)
+			SELECT $2, ot.id, $4
+			FROM option_types ot
+			WHERE lower(ot.name) = lower($3) AND ot.is_deleted = false
+				AND EXISTS (SELECT 1 FROM category_option_types cot JOIN path ON cot.category_id = path.id WHERE cot.option_type_id = ot.id)
+		`, productID, variantID, strings.TrimSpace(name), strings.TrimSpace(value), maxCategoryDepth)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrUnknownOptionType
+		}
+	}
+
+	return nil
+}
+
+// writeVariantOptions sets the option values of a newly inserted variant and its
+// options_key, and loads the stored values into variant.
+func writeVariantOptions(ctx context.Context, q querier, productID string, variant *models.ProductVariant, options map[string]string) error {
+	if len(options) > 0 {
+		if err := setVariantOptions(ctx, q, productID, variant.ID.String(), options); err != nil {
+			return err
+		}
+	}
+	if err := refreshVariantOptionsKey(ctx, q, variant.ID.String()); err != nil {
+		return err
+	}
+	return loadVariantOptions(ctx, q, []*models.ProductVariant{variant})
+}
+
+// loadVariantOptions fills in the Options of variants.
+func loadVariantOptions(ctx context.Context, q querier, variants []*models.ProductVariant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(variants))
+	for i, variant := range variants {
+		ids[i] = variant.ID.String()
+	}
+
+	options, err := variantOptions(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		variant.Options = options[variant.ID.String()]
+	}
+	return nil
+}
+
+// variantOptions returns the option values of the given variants, keyed by
+// variant ID and then by option type name.
+func variantOptions(ctx context.Context, q querier, variantIDs []string) (map[string]map[string]string, error) {
+	rows, err := q.Query(ctx, `
+		SELECT vov.variant_id::text, ot.name, vov.value
+		FROM variant_option_values vov
+		JOIN option_types ot ON ot.id = vov.option_type_id
+		WHERE vov.variant_id = ANY($1::uuid[])
+	`, variantIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := map[string]map[string]string{}
+	for rows.Next() {
+		var variantID, name, value string
+		if err := rows.Scan(&variantID, &name, &value); err != nil {
+			return nil, err
+		}
+		if options[variantID] == nil {
+			options[variantID] = map[string]string{}
+		}
+		options[variantID][name] = value
+	}
+
+	return options, rows.Err()
+}
+
+// productAttributes returns the descriptive attributes of a product, such as
+// fabric or country of origin, keyed by name.
+func productAttributes(ctx context.Context, q querier, productID string) (map[string]string, error) {
+	attributes, err := productsAttributes(ctx, q, []string{productID})
+	return attributes[productID], err
+}
+
+// productsAttributes returns the attributes of the given products in one query,
+// keyed by product ID. Products without attributes have an empty map.
+func productsAttributes(ctx context.Context, q querier, productIDs []string) (map[string]map[string]string, error) {
+	rows, err := q.Query(ctx, "SELECT product_id::text, name, value FROM product_attributes WHERE product_id = ANY($1::uuid[])", productIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := make(map[string]map[string]string, len(productIDs))
+	for _, id := range productIDs {
+		attributes[id] = map[string]string{}
+	}
+	for rows.Next() {
+		var productID, name, value string
+		if err := rows.Scan(&productID, &name, &value); err != nil {
+			return nil, err
+		}
+		attributes[productID][name] = value
+	}
+
+	return attributes, rows.Err()
+}
+
+// setProductAttributes replaces the attributes of a product.
+func setProductAttributes(ctx context.Context, q querier, productID string, attributes map[string]string) error {
+	if _, err := q.Exec(ctx, "DELETE FROM product_attributes WHERE product_id = $1", productID); err != nil {
+		return err
+	}
+
+	for name, value := range attributes {
+		_, err := q.Exec(ctx, "INSERT INTO product_attributes (product_id, name, value) VALUES ($1, $2, $3)",
+			productID, strings.TrimSpace(name), strings.TrimSpace(value))
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicateAttribute
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of m in order, so that queries built from a map
+// are the same on every call.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,70 +3,140 @@ package repositories
 import (
 	"clothes-shop-api/internal/models"
 	"context"
-	"strconv"
+	"errors"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type ProductRepository struct {
-	DB *pgxpool.Pool
-}
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrSlugTaken       = errors.New("slug is already used by another product")
+	ErrVariantNotFound = errors.New("product variant not found")
+	ErrVariantExists   = errors.New("a variant with these options already exists")
+	ErrSKUTaken        = errors.New("SKU is already used by another variant")
+	ErrBarcodeTaken    = errors.New("barcode is already used by another variant")
+	// ErrDuplicateAttribute is returned when two attributes of a product differ only in case.
+	ErrDuplicateAttribute = errors.New("attribute names must be unique")
+	// ErrCursorNotSupported is returned for a cursor on a listing that is not
+	// ordered newest first, such as search results ordered by relevance or
+	// products sorted by price.
+	ErrCursorNotSupported = errors.New("cursor pagination is only available for listings ordered newest first")
+)
 
-func NewProductRepository(db *pgxpool.Pool) *ProductRepository {
-	return &ProductRepository{DB: db}
+// variantColumns is the column list read by scanVariant.
+const variantColumns = `id, product_id, sku, barcode, size, color, stock, price, image, created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+// VariantInput holds the fields of a variant to create. ID is only used by
+// UpdateProduct to match an existing variant. An empty SKU is generated from
+// the SKU pattern, and an empty barcode is left unset.
+type VariantInput struct {
+	ID      *string
+	SKU     string
+	Barcode string
+	Size    string
+	Color   string
+	Stock   int
+	Price   float64
+	Image   string
+	// Options holds the values of option types other than size and color, keyed
+	// by option type name. The product's category must offer each option type.
+	Options map[string]string
 }
 
-func (r *ProductRepository) GetAllProducts(ctx context.Context, page, limit int, minPrice, maxPrice *float64, categoryName, brandName, searchName *string) ([]models.Product, error) {
-	offset := (page - 1) * limit
+// VariantUpdate holds the variant fields to change; nil fields are left as they are.
+type VariantUpdate struct {
+	SKU *string
+	// Barcode is cleared when set to an empty string.
+	Barcode *string
+	Size    *string
+	Color   *string
+	Stock   *int
+	Price   *float64
+	Image   *string
+	// Options replaces all option values other than size and color when not nil.
+	Options map[string]string
+}
 
-	query := `
-		SELECT p.id, p.name, p.description, p.min_price, p.max_price, p.total_stock, p.category_id, p.brand_id, p.created_at, p.updated_at, p.is_active, p.is_deleted
-		FROM products p
-		JOIN categories c ON p.category_id = c.id
-		LEFT JOIN brands b ON p.brand_id = b.id
-		WHERE p.is_active = true AND p.is_deleted = false
-	`
+// ProductInput holds the fields of a product to create or update.
+type ProductInput struct {
+	Name         string
+	Slug         string
+	Description  string
+	CategoryName string
+	BrandName    string
+	// Attributes are descriptive name/value pairs such as fabric or care
+	// instructions. UpdateProduct keeps the current attributes when nil.
+	Attributes map[string]string
+	Variants   []VariantInput
+}
 
-	args := []interface{}{}
-	argCount := 0
+type ProductRepository struct {
+	DB *pgxpool.Pool
+	// SKUPattern generates the SKU of variants created without one, see FormatSKU.
+	SKUPattern string
+	// PriceBuckets are the price ranges GetProductFacets counts products in.
+	PriceBuckets []PriceRange
+}
 
-	if minPrice != nil {
-		argCount++
-		query += ` AND p.min_price >= $` + strconv.Itoa(argCount)
-		args = append(args, *minPrice)
+func NewProductRepository(db *pgxpool.Pool, skuPattern string, priceBuckets []PriceRange) *ProductRepository {
+	if skuPattern == "" {
+		skuPattern = defaultSKUPattern
 	}
+	return &ProductRepository{DB: db, SKUPattern: skuPattern, PriceBuckets: priceBuckets}
+}
 
-	if maxPrice != nil {
-		argCount++
-		query += ` AND p.max_price <= $` + strconv.Itoa(argCount)
-		args = append(args, *maxPrice)
+// GetAllProducts returns a page of the active products that match filter in the
+// order of the sort keys, see ParseProductSort.
+func (r *ProductRepository) GetAllProducts(ctx context.Context, pagination Pagination, filter ProductFilter, sort []string) (Page[models.Product], error) {
+	orderBy, err := productOrderBy(sort, filter.Search != nil)
+	if err != nil {
+		return Page[models.Product]{}, err
 	}
-
-	if categoryName != nil {
-		argCount++
-		query += ` AND c.name ILIKE $` + strconv.Itoa(argCount)
-		args = append(args, "%"+*categoryName+"%")
+	newestFirst := isNewestFirst(sort, filter.Search != nil)
+	if pagination.After != nil && !newestFirst {
+		return Page[models.Product]{}, ErrCursorNotSupported
 	}
 
-	if brandName != nil {
-		argCount++
-		query += ` AND b.name ILIKE $` + strconv.Itoa(argCount)
-		args = append(args, "%"+*brandName+"%")
+	args := sqlArgs{}
+	where := productWhere(productConditions(filter), "", &args)
+
+	var total int
+	err = r.DB.QueryRow(ctx, `
+		SELECT count(*)
+		FROM products p
+		LEFT JOIN brands b ON p.brand_id = b.id
+		WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return Page[models.Product]{}, err
 	}
 
-	if searchName != nil {
-		argCount++
-		query += ` AND p.name ILIKE $` + strconv.Itoa(argCount)
-		args = append(args, "%"+*searchName+"%")
+	columns := `p.id, p.name, p.slug, p.description, p.min_price, p.max_price, p.total_stock, p.sold_count, p.rating_avg, p.rating_count, p.category_id, p.brand_id, p.created_by, p.created_at, p.updated_by, p.updated_at, p.is_active, p.is_deleted`
+	from := `
+		FROM products p
+		LEFT JOIN brands b ON p.brand_id = b.id`
+
+	// A search matches the weighted document over name, brand, category and
+	// description, and is ordered by relevance unless sorted otherwise.
+	if filter.Search != nil {
+		columns += `, ts_rank_cd(p.search_vector, search.query),
+			ts_headline('` + searchConfig + `', p.name, search.query, '` + nameHighlightOptions + `'),
+			ts_headline('` + searchConfig + `', COALESCE(p.description, ''), search.query, '` + descriptionHighlightOptions + `')`
+		from += `
+		CROSS JOIN websearch_to_tsquery('` + searchConfig + `', ` + args.add(*filter.Search) + `) AS search(query)`
 	}
 
-	query += ` ORDER BY p.created_at DESC LIMIT $` + strconv.Itoa(argCount+1) + ` OFFSET $` + strconv.Itoa(argCount+2)
-	args = append(args, limit, offset)
+	query := `
+		SELECT ` + columns + from + `
+		WHERE ` + where + pagination.keyset(&args, "p.") + `
+		ORDER BY ` + orderBy + pagination.limit(&args)
 
 	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return Page[models.Product]{}, err
 	}
 	defer rows.Close()
 
@@ -74,323 +144,774 @@ func (r *ProductRepository) GetAllProducts(ctx context.Context, page, limit int,
 	for rows.Next() {
 		var product models.Product
 		var brandID *string
-		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.CategoryID, &brandID,
-			&product.CreatedAt, &product.UpdatedAt, &product.IsActive, &product.IsDeleted)
-		if err != nil {
-			return nil, err
+		dest := []any{&product.ID, &product.Name, &product.Slug, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.SoldCount, &product.RatingAvg, &product.RatingCount, &product.CategoryID, &brandID,
+			&product.CreatedBy, &product.CreatedAt, &product.UpdatedBy, &product.UpdatedAt, &product.IsActive, &product.IsDeleted}
+		if filter.Search != nil {
+			product.SearchRank = new(float64)
+			product.Highlight = &models.ProductHighlight{}
+			dest = append(dest, product.SearchRank, &product.Highlight.Name, &product.Highlight.Description)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return Page[models.Product]{}, err
 		}
 		if brandID != nil {
 			parsedUUID, err := uuid.Parse(*brandID)
 			if err != nil {
-				return nil, err
+				return Page[models.Product]{}, err
 			}
 			product.BrandID = &parsedUUID
 		}
-		// Load variants
-		variants, err := r.GetProductVariants(ctx, product.ID.String())
-		if err != nil {
-			return nil, err
-		}
-		product.Variants = variants
 		products = append(products, product)
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Page[models.Product]{}, err
+	}
+
+	if err := loadProductListDetails(ctx, r.DB, products); err != nil {
+		return Page[models.Product]{}, err
+	}
 
-	return products, nil
+	// Cursors are positions in the newest first order; other orders are paged by number only
+	var cursor func(models.Product) Cursor
+	if newestFirst {
+		cursor = func(product models.Product) Cursor {
+			return Cursor{CreatedAt: product.CreatedAt, ID: product.ID}
+		}
+	}
+
+	return newPage(products, total, pagination, cursor), nil
 }
 
-func (r *ProductRepository) CreateProduct(ctx context.Context, name, description string, minPrice, maxPrice float64, totalStock int, categoryName, brandName string) (*models.Product, error) {
-	// First, get the category ID by name
-	var categoryID string
-	err := r.DB.QueryRow(ctx, "SELECT id FROM categories WHERE name = $1", categoryName).Scan(&categoryID)
-	if err != nil {
-		return nil, err
+// loadProductListDetails fills in the breadcrumbs, attributes and variants of
+// products with one query each rather than one per product.
+func loadProductListDetails(ctx context.Context, q querier, products []models.Product) error {
+	if len(products) == 0 {
+		return nil
 	}
 
-	// Get brand ID by name if provided
-	var brandID *string
-	if brandName != "" {
-		var bid string
-		err := r.DB.QueryRow(ctx, "SELECT id FROM brands WHERE name = $1", brandName).Scan(&bid)
-		if err != nil {
-			return nil, err
+	productIDs := make([]string, len(products))
+	var categoryIDs []string
+	seen := map[string]bool{}
+	for i, product := range products {
+		productIDs[i] = product.ID.String()
+		if !seen[product.CategoryID] {
+			seen[product.CategoryID] = true
+			categoryIDs = append(categoryIDs, product.CategoryID)
 		}
-		brandID = &bid
 	}
 
+	breadcrumbs, err := categoriesBreadcrumbs(ctx, q, categoryIDs)
+	if err != nil {
+		return err
+	}
+	attributes, err := productsAttributes(ctx, q, productIDs)
+	if err != nil {
+		return err
+	}
+	variants, err := productsVariants(ctx, q, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		products[i].Breadcrumbs = breadcrumbs[products[i].CategoryID]
+		products[i].Attributes = attributes[productIDs[i]]
+		products[i].Variants = variants[productIDs[i]]
+	}
+	return nil
+}
+
+// GetProductByID returns an active, non-deleted product with its category, brand and variants.
+func (r *ProductRepository) GetProductByID(ctx context.Context, id string) (*models.Product, error) {
+	return r.getProductDetail(ctx, "p.id = $1", id)
+}
+
+// GetProductBySlug returns an active, non-deleted product with its category, brand and variants.
+func (r *ProductRepository) GetProductBySlug(ctx context.Context, slug string) (*models.Product, error) {
+	return r.getProductDetail(ctx, "p.slug = $1", slug)
+}
+
+func (r *ProductRepository) getProductDetail(ctx context.Context, where string, arg any) (*models.Product, error) {
 	query := `
-		INSERT INTO products (name, description, min_price, max_price, total_stock, category_id, brand_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, name, description, min_price, max_price, total_stock, category_id, brand_id, created_at, updated_at, is_active, is_deleted, created_by, updated_by
+		SELECT p.id, p.name, p.slug, p.description, p.min_price, p.max_price, p.total_stock, p.sold_count, p.rating_avg, p.rating_count, p.category_id, p.brand_id, p.created_by, p.created_at, p.updated_by, p.updated_at, p.is_active, p.is_deleted,
+			c.id, c.parent_id, c.name, COALESCE(c.description, ''), b.id, b.name, COALESCE(b.description, ''), b.logo_url
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		LEFT JOIN brands b ON p.brand_id = b.id
+		WHERE ` + where + ` AND p.is_active = true AND p.is_deleted = false
 	`
 
 	var product models.Product
-	err = r.DB.QueryRow(ctx, query, name, description, minPrice, maxPrice, totalStock, categoryID, brandID).Scan(
-		&product.ID, &product.Name, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.CategoryID, &brandID,
-		&product.CreatedAt, &product.UpdatedAt, &product.IsActive, &product.IsDeleted, &product.CreatedBy, &product.UpdatedBy,
+	var categoryID, categoryParentID, brandID *uuid.UUID
+	var categoryName, categoryDescription, brandName, brandDescription, brandLogoURL *string
+	err := r.DB.QueryRow(ctx, query, arg).Scan(
+		&product.ID, &product.Name, &product.Slug, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.SoldCount, &product.RatingAvg, &product.RatingCount, &product.CategoryID, &product.BrandID,
+		&product.CreatedBy, &product.CreatedAt, &product.UpdatedBy, &product.UpdatedAt, &product.IsActive, &product.IsDeleted,
+		&categoryID, &categoryParentID, &categoryName, &categoryDescription, &brandID, &brandName, &brandDescription, &brandLogoURL,
 	)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
 		return nil, err
 	}
 
-	if brandID != nil {
-		parsedUUID, err := uuid.Parse(*brandID)
+	if categoryID != nil {
+		product.Category = &models.Category{ID: *categoryID, ParentID: categoryParentID, Name: *categoryName, Description: *categoryDescription}
+		product.Breadcrumbs, err = categoryBreadcrumbs(ctx, r.DB, categoryID.String())
 		if err != nil {
 			return nil, err
 		}
-		product.BrandID = &parsedUUID
 	}
+	if brandID != nil {
+		product.Brand = &models.Brand{ID: *brandID, Name: *brandName, Description: *brandDescription, LogoURL: *brandLogoURL}
+	}
+
+	product.Attributes, err = productAttributes(ctx, r.DB, product.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	product.Images, err = productImages(ctx, r.DB, product.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := r.GetProductVariants(ctx, product.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	product.Variants = variants
 
 	return &product, nil
 }
 
-func (r *ProductRepository) GetProductVariants(ctx context.Context, productID string) ([]models.ProductVariant, error) {
-	query := `
-		SELECT id, product_id, size, color, stock, price, image, created_by, created_at, updated_by, updated_at, is_active, is_deleted
-		FROM product_variants
-		WHERE product_id = $1 AND is_active = true AND is_deleted = false
-		ORDER BY created_at
-	`
+// productColumns is the column list read by scanProduct.
+const productColumns = `id, name, slug, description, min_price, max_price, total_stock, sold_count, rating_avg, rating_count, category_id, brand_id, created_by, created_at, updated_by, updated_at, is_active, is_deleted`
 
-	rows, err := r.DB.Query(ctx, query, productID)
+// scanProduct reads a row selected with productColumns.
+func scanProduct(row pgx.Row) (*models.Product, error) {
+	var product models.Product
+	err := row.Scan(&product.ID, &product.Name, &product.Slug, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.SoldCount, &product.RatingAvg, &product.RatingCount, &product.CategoryID, &product.BrandID,
+		&product.CreatedBy, &product.CreatedAt, &product.UpdatedBy, &product.UpdatedAt, &product.IsActive, &product.IsDeleted)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrSlugTaken
+		}
 		return nil, err
 	}
-	defer rows.Close()
+	return &product, nil
+}
 
-	var variants []models.ProductVariant
-	for rows.Next() {
-		var variant models.ProductVariant
-		err := rows.Scan(&variant.ID, &variant.ProductID, &variant.Size, &variant.Color, &variant.Stock, &variant.Price, &variant.Image,
-			&variant.CreatedBy, &variant.CreatedAt, &variant.UpdatedBy, &variant.UpdatedAt, &variant.IsActive, &variant.IsDeleted)
-		if err != nil {
-			return nil, err
+// lookupCategoryAndBrand resolves the category name and the optional brand name
+// of a product. Names are not case-sensitive, like their unique indexes.
+func lookupCategoryAndBrand(ctx context.Context, q querier, categoryName, brandName string) (string, *string, error) {
+	var categoryID string
+	err := q.QueryRow(ctx, "SELECT id FROM categories WHERE lower(name) = lower($1) AND is_deleted = false", categoryName).Scan(&categoryID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, ErrCategoryNotFound
 		}
-		variants = append(variants, variant)
+		return "", nil, err
 	}
 
-	return variants, nil
+	if brandName == "" {
+		return categoryID, nil, nil
+	}
+
+	var brandID string
+	err = q.QueryRow(ctx, "SELECT id FROM brands WHERE lower(name) = lower($1) AND is_deleted = false", brandName).Scan(&brandID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, ErrBrandNotFound
+		}
+		return "", nil, err
+	}
+
+	return categoryID, &brandID, nil
 }
 
-func (r *ProductRepository) UpdateProduct(ctx context.Context, id string, name, description string, minPrice, maxPrice float64, totalStock int, categoryName, brandName string, updatedBy *string) (*models.Product, error) {
-	// First, get the category ID by name
-	var categoryID string
-	err := r.DB.QueryRow(ctx, "SELECT id FROM categories WHERE name = $1", categoryName).Scan(&categoryID)
+// lockProduct takes the row lock that serializes writes to a product and its
+// variants, so that refreshProductAggregates reads every committed variant. It
+// fails with ErrProductNotFound when the product is deleted.
+func lockProduct(ctx context.Context, q querier, productID string) error {
+	var id string
+	err := q.QueryRow(ctx, "SELECT id FROM products WHERE id = $1 AND is_deleted = false FOR UPDATE", productID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrProductNotFound
+	}
+	return err
+}
+
+// refreshProductAggregates recomputes min_price, max_price and total_stock from the
+// active variants of a product. Every write to product_variants calls it in the
+// same transaction, so the aggregates always match the variants.
+func refreshProductAggregates(ctx context.Context, q querier, productID string) error {
+	_, err := q.Exec(ctx, `
+		UPDATE products
+		SET min_price = COALESCE(v.min_price, 0), max_price = COALESCE(v.max_price, 0), total_stock = COALESCE(v.total_stock, 0)
+		FROM (
+			SELECT min(price) AS min_price, max(price) AS max_price, sum(stock) AS total_stock
+			FROM product_variants
+			WHERE product_id = $1 AND is_active = true AND is_deleted = false
+		) v
+		WHERE products.id = $1
+	`, productID)
+	return err
+}
+
+// loadProduct reads a product with its attributes and active variants.
+func loadProduct(ctx context.Context, q querier, productID string) (*models.Product, error) {
+	product, err := scanProduct(q.QueryRow(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1", productID))
+	if err != nil {
+		return nil, err
+	}
+
+	product.Attributes, err = productAttributes(ctx, q, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Variants, err = productVariants(ctx, q, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// CreateProduct inserts a product with its attributes and variants in one
+// transaction. When the slug is empty one is generated from the name. The price
+// range and stock are computed from the variants.
+func (r *ProductRepository) CreateProduct(ctx context.Context, input ProductInput, createdBy *string) (*models.Product, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	categoryID, brandID, err := lookupCategoryAndBrand(ctx, tx, input.CategoryName, input.BrandName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get brand ID by name if provided
-	var brandID *string
-	if brandName != "" {
-		var bid string
-		err := r.DB.QueryRow(ctx, "SELECT id FROM brands WHERE name = $1", brandName).Scan(&bid)
+	slug := input.Slug
+	if slug == "" {
+		slug, err = uniqueProductSlug(ctx, tx, Slugify(input.Name), nil)
 		if err != nil {
 			return nil, err
 		}
-		brandID = &bid
 	}
 
 	query := `
-		UPDATE products
-		SET name = $2, description = $3, min_price = $4, max_price = $5, total_stock = $6, category_id = $7, brand_id = $8, updated_at = now()
-		WHERE id = $1
-		RETURNING id, name, description, min_price, max_price, total_stock, category_id, brand_id, created_at, updated_at, is_active
-	`
+		INSERT INTO products (name, slug, description, min_price, max_price, total_stock, category_id, brand_id, created_by, updated_by)
+		VALUES ($1, $2, $3, 0, 0, 0, $4, $5, $6, $6)
+		RETURNING ` + productColumns
 
-	var product models.Product
-	err = r.DB.QueryRow(ctx, query, id, name, description, minPrice, maxPrice, totalStock, categoryID, brandID).Scan(
-		&product.ID, &product.Name, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.CategoryID, &brandID,
-		&product.CreatedAt, &product.UpdatedAt, &product.IsActive,
-	)
+	product, err := scanProduct(tx.QueryRow(ctx, query, input.Name, slug, input.Description, categoryID, brandID, createdBy))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := refreshProductSearch(ctx, tx, "p.id = $1", product.ID); err != nil {
+		return nil, err
+	}
+	if err := setProductAttributes(ctx, tx, product.ID.String(), input.Attributes); err != nil {
+		return nil, err
+	}
+	if _, err := r.insertVariants(ctx, tx, product.ID.String(), input.Variants, createdBy); err != nil {
+		return nil, err
+	}
+	if err := refreshProductAggregates(ctx, tx, product.ID.String()); err != nil {
+		return nil, err
+	}
 
+	product, err = loadProduct(ctx, tx, product.ID.String())
 	if err != nil {
 		return nil, err
 	}
 
-	if brandID != nil {
-		parsedUUID, err := uuid.Parse(*brandID)
-		if err != nil {
-			return nil, err
+	return product, tx.Commit(ctx)
+}
+
+// scanVariant reads a row selected with variantColumns.
+func scanVariant(row pgx.Row) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	err := row.Scan(&variant.ID, &variant.ProductID, &variant.SKU, &variant.Barcode, &variant.Size, &variant.Color, &variant.Stock, &variant.Price, &variant.Image,
+		&variant.CreatedBy, &variant.CreatedAt, &variant.UpdatedBy, &variant.UpdatedAt, &variant.IsActive, &variant.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrVariantNotFound
 		}
-		product.BrandID = &parsedUUID
+		return nil, variantUniqueError(err)
 	}
+	return &variant, nil
+}
 
-	return &product, nil
+// variantUniqueError maps unique violations on product_variants to their errors.
+func variantUniqueError(err error) error {
+	switch uniqueViolationConstraint(err) {
+	case "idx_product_variants_options":
+		return ErrVariantExists
+	case "idx_product_variants_sku":
+		return ErrSKUTaken
+	case "idx_product_variants_barcode":
+		return ErrBarcodeTaken
+	}
+	return err
 }
 
-func (r *ProductRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+// GetVariantBySKU returns an active variant of an active product by its SKU.
+func (r *ProductRepository) GetVariantBySKU(ctx context.Context, sku string) (*models.ProductVariant, error) {
+	return r.getActiveVariant(ctx, "sku = upper($1)", sku)
+}
+
+// GetVariantByBarcode returns an active variant of an active product by its
+// barcode. UPC-A codes also match the same code written as EAN-13 with a leading zero.
+func (r *ProductRepository) GetVariantByBarcode(ctx context.Context, code string) (*models.ProductVariant, error) {
+	return r.getActiveVariant(ctx, "lpad(barcode, 14, '0') = lpad($1, 14, '0')", code)
+}
+
+func (r *ProductRepository) getActiveVariant(ctx context.Context, where string, arg any) (*models.ProductVariant, error) {
 	query := `
-		SELECT id, name
-		FROM categories
-		ORDER BY name
+		SELECT ` + variantColumns + `
+		FROM product_variants
+		WHERE ` + where + ` AND is_active = true AND is_deleted = false
+			AND EXISTS (SELECT 1 FROM products p WHERE p.id = product_variants.product_id AND p.is_active = true AND p.is_deleted = false)
 	`
 
-	rows, err := r.DB.Query(ctx, query)
+	variant, err := scanVariant(r.DB.QueryRow(ctx, query, arg))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var categories []models.Category
-	for rows.Next() {
-		var category models.Category
-		err := rows.Scan(&category.ID, &category.Name)
-		if err != nil {
-			return nil, err
-		}
-		categories = append(categories, category)
+	if err := loadVariantOptions(ctx, r.DB, []*models.ProductVariant{variant}); err != nil {
+		return nil, err
 	}
+	return variant, nil
+}
 
-	return categories, nil
+func (r *ProductRepository) GetProductVariants(ctx context.Context, productID string) ([]models.ProductVariant, error) {
+	return productVariants(ctx, r.DB, productID)
 }
 
-func (r *ProductRepository) GetAllBrands(ctx context.Context) ([]models.Brand, error) {
+// productVariants returns the active variants of a product.
+func productVariants(ctx context.Context, q querier, productID string) ([]models.ProductVariant, error) {
+	variants, err := productsVariants(ctx, q, []string{productID})
+	return variants[productID], err
+}
+
+// productsVariants returns the active variants of the given products in one
+// query, keyed by product ID. Products without variants have an empty slice.
+func productsVariants(ctx context.Context, q querier, productIDs []string) (map[string][]models.ProductVariant, error) {
 	query := `
-		SELECT id, name
-		FROM brands
-		ORDER BY name
+		SELECT ` + variantColumns + `
+		FROM product_variants
+		WHERE product_id = ANY($1::uuid[]) AND is_active = true AND is_deleted = false
+		ORDER BY product_id, created_at
 	`
 
-	rows, err := r.DB.Query(ctx, query)
+	rows, err := q.Query(ctx, query, productIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var brands []models.Brand
+	variants := make(map[string][]models.ProductVariant, len(productIDs))
+	for _, id := range productIDs {
+		variants[id] = []models.ProductVariant{}
+	}
 	for rows.Next() {
-		var brand models.Brand
-		err := rows.Scan(&brand.ID, &brand.Name)
+		variant, err := scanVariant(rows)
 		if err != nil {
 			return nil, err
 		}
-		brands = append(brands, brand)
+		productID := variant.ProductID
+		variants[productID] = append(variants[productID], *variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return brands, nil
+	var loaded []*models.ProductVariant
+	for _, list := range variants {
+		for i := range list {
+			loaded = append(loaded, &list[i])
+		}
+	}
+	if err := loadVariantOptions(ctx, q, loaded); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
 }
 
-func (r *ProductRepository) ToggleActive(ctx context.Context, id string, updatedBy *string) (*models.Product, error) {
+// UpdateProduct updates a product, its attributes and its variants in one
+// transaction, see syncProductVariants. The slug is kept when it is empty, so
+// renaming a product does not break existing links.
+func (r *ProductRepository) UpdateProduct(ctx context.Context, id string, input ProductInput, updatedBy *string) (*models.Product, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	categoryID, brandID, err := lookupCategoryAndBrand(ctx, tx, input.CategoryName, input.BrandName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The update also takes the product lock used by the variant writes
 	query := `
 		UPDATE products
-		SET is_active = NOT is_active, updated_at = now()
-		WHERE id = $1
-		RETURNING id, name, description, min_price, max_price, total_stock, category_id, brand_id, created_by, created_at, updated_by, updated_at, is_active, is_deleted
-	`
+		SET name = $2, slug = COALESCE(NULLIF($3, ''), slug), description = $4, category_id = $5, brand_id = $6, updated_by = $7, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + productColumns
 
-	var product models.Product
-	err := r.DB.QueryRow(ctx, query, id).Scan(
-		&product.ID, &product.Name, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.CategoryID, &product.BrandID,
-		&product.CreatedBy, &product.CreatedAt, &product.UpdatedBy, &product.UpdatedAt, &product.IsActive, &product.IsDeleted,
-	)
+	if _, err := scanProduct(tx.QueryRow(ctx, query, id, input.Name, input.Slug, input.Description, categoryID, brandID, updatedBy)); err != nil {
+		return nil, err
+	}
+
+	if err := refreshProductSearch(ctx, tx, "p.id = $1", id); err != nil {
+		return nil, err
+	}
+	if input.Attributes != nil {
+		if err := setProductAttributes(ctx, tx, id, input.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.syncProductVariants(ctx, tx, id, input.Variants, updatedBy); err != nil {
+		return nil, err
+	}
+	if err := refreshProductAggregates(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	product, err := loadProduct(ctx, tx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return &product, nil
+	return product, tx.Commit(ctx)
+}
+
+func (r *ProductRepository) ToggleActive(ctx context.Context, id string, updatedBy *string) (*models.Product, error) {
+	query := `
+		UPDATE products
+		SET is_active = NOT is_active, updated_by = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING ` + productColumns
+
+	return scanProduct(r.DB.QueryRow(ctx, query, id, updatedBy))
 }
 
 func (r *ProductRepository) SoftDelete(ctx context.Context, id string, updatedBy *string) (*models.Product, error) {
 	query := `
 		UPDATE products
-		SET is_deleted = true, updated_at = now()
+		SET is_deleted = true, updated_by = $2, updated_at = now()
 		WHERE id = $1
-		RETURNING id, name, description, min_price, max_price, total_stock, category_id, brand_id, created_by, created_at, updated_by, updated_at, is_active, is_deleted
-	`
+		RETURNING ` + productColumns
 
-	var product models.Product
-	err := r.DB.QueryRow(ctx, query, id).Scan(
-		&product.ID, &product.Name, &product.Description, &product.MinPrice, &product.MaxPrice, &product.TotalStock, &product.CategoryID, &product.BrandID,
-		&product.CreatedBy, &product.CreatedAt, &product.UpdatedBy, &product.UpdatedAt, &product.IsActive, &product.IsDeleted,
-	)
+	return scanProduct(r.DB.QueryRow(ctx, query, id, updatedBy))
+}
+
+// insertVariants adds variants with their option values to a product, generating
+// the SKUs that are not given. The caller refreshes the aggregates.
+func (r *ProductRepository) insertVariants(ctx context.Context, q querier, productID string, variants []VariantInput, createdBy *string) ([]models.ProductVariant, error) {
+	query := `
+		INSERT INTO product_variants (product_id, sku, barcode, size, color, stock, price, image, created_by, updated_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $9)
+		RETURNING ` + variantColumns
+
+	var fields *SKUFields
+	inserted := make([]models.ProductVariant, 0, len(variants))
+	for _, variant := range variants {
+		sku := variant.SKU
+		if sku == "" {
+			if fields == nil {
+				var err error
+				if fields, err = productSKUFields(ctx, q, productID); err != nil {
+					return nil, err
+				}
+			}
+
+			variantFields := *fields
+			variantFields.Size, variantFields.Color = variant.Size, variant.Color
+			var err error
+			if sku, err = uniqueVariantSKU(ctx, q, FormatSKU(r.SKUPattern, variantFields)); err != nil {
+				return nil, err
+			}
+		}
+
+		created, err := scanVariant(q.QueryRow(ctx, query, productID, sku, variant.Barcode, variant.Size, variant.Color, variant.Stock, variant.Price, variant.Image, createdBy))
+		if err != nil {
+			return nil, err
+		}
+		if err := writeVariantOptions(ctx, q, productID, created, variant.Options); err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, *created)
+	}
+
+	return inserted, nil
+}
+
+// productSKUFields reads the product values used in generated SKUs.
+func productSKUFields(ctx context.Context, q querier, productID string) (*SKUFields, error) {
+	var fields SKUFields
+	err := q.QueryRow(ctx, `
+		SELECT p.slug, COALESCE(b.name, ''), COALESCE(c.name, '')
+		FROM products p
+		LEFT JOIN brands b ON p.brand_id = b.id
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id = $1
+	`, productID).Scan(&fields.Product, &fields.Brand, &fields.Category)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
 		return nil, err
 	}
-
-	return &product, nil
+	return &fields, nil
 }
 
-func (r *ProductRepository) CreateProductVariants(ctx context.Context, productID string, variants []struct {
-	Size  string
-	Color string
-	Stock int
-	Price float64
-	Image string
-}) error {
-	if len(variants) == 0 {
-		return nil
+// syncProductVariants makes the variants of a product match variants. Each entry
+// updates the existing variant with the same ID, or else the one with the same
+// options, and is inserted when neither exists. Variants that are not
+// matched are soft-deleted, so matched variants keep their IDs and deleted ones
+// stay in place for carts and orders that reference them. An empty SKU or barcode
+// keeps the current one. The caller holds the product lock and refreshes the aggregates.
+func (r *ProductRepository) syncProductVariants(ctx context.Context, q querier, productID string, variants []VariantInput, updatedBy *string) error {
+	rows, err := q.Query(ctx, `
+		SELECT id::text, COALESCE(options_key, '')
+		FROM product_variants
+		WHERE product_id = $1 AND is_deleted = false
+	`, productID)
+	if err != nil {
+		return err
 	}
 
-	query := `
-		INSERT INTO product_variants (product_id, size, color, stock, price, image)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
+	existing := map[string]bool{}
+	byOptions := map[string]string{}
+	for rows.Next() {
+		var variantID, optionsKey string
+		if err := rows.Scan(&variantID, &optionsKey); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[variantID] = true
+		byOptions[optionsKey] = variantID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
+	// Match every entry before writing, so unmatched variants can be removed first
+	type variantMatch struct {
+		id      string
+		variant VariantInput
+	}
+	var updates []variantMatch
+	var inserts []VariantInput
+	kept := []string{}
 	for _, variant := range variants {
-		_, err := r.DB.Exec(ctx, query, productID, variant.Size, variant.Color, variant.Stock, variant.Price, variant.Image)
+		variantID := byOptions[variantOptionsKey(variant.Size, variant.Color, variant.Options)]
+		if variant.ID != nil {
+			if !existing[strings.ToLower(*variant.ID)] {
+				return ErrVariantNotFound
+			}
+			variantID = strings.ToLower(*variant.ID)
+		}
+
+		if variantID == "" || slices.Contains(kept, variantID) {
+			inserts = append(inserts, variant)
+			continue
+		}
+		kept = append(kept, variantID)
+		updates = append(updates, variantMatch{id: variantID, variant: variant})
+	}
+
+	// Removing the unmatched variants first frees their options for the entries,
+	// and clearing the options keys of the kept ones lets them swap options
+	_, err = q.Exec(ctx, `
+		UPDATE product_variants
+		SET is_deleted = true, updated_by = $2, updated_at = now()
+		WHERE product_id = $1 AND is_deleted = false AND NOT (id::text = ANY($3::text[]))
+	`, productID, updatedBy, kept)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `UPDATE product_variants SET options_key = NULL WHERE id::text = ANY($1::text[])`, kept)
+	if err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		variant := update.variant
+		_, err := q.Exec(ctx, `
+			UPDATE product_variants
+			SET size = $2, color = $3, stock = $4, price = $5, image = $6,
+				sku = COALESCE(NULLIF($8, ''), sku), barcode = COALESCE(NULLIF($9, ''), barcode),
+				updated_by = $7, updated_at = now()
+			WHERE id = $1 AND (size, color, stock, price, image, sku, barcode)
+				IS DISTINCT FROM ($2, $3, $4, $5, $6, COALESCE(NULLIF($8, ''), sku), COALESCE(NULLIF($9, ''), barcode))
+		`, update.id, variant.Size, variant.Color, variant.Stock, variant.Price, variant.Image, updatedBy, variant.SKU, variant.Barcode)
 		if err != nil {
+			return variantUniqueError(err)
+		}
+		if err := setVariantOptions(ctx, q, productID, update.id, variant.Options); err != nil {
+			return err
+		}
+		if err := refreshVariantOptionsKey(ctx, q, update.id); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	_, err = r.insertVariants(ctx, q, productID, inserts, updatedBy)
+	return err
 }
 
-func (r *ProductRepository) UpdateProductVariants(ctx context.Context, productID string, variants []struct {
-	Size  string
-	Color string
-	Stock int
-	Price float64
-	Image string
-}) error {
-	// First, delete existing variants
-	_, err := r.DB.Exec(ctx, "DELETE FROM product_variants WHERE product_id = $1", productID)
+// writeVariant runs a statement that changes one variant of productID and returns
+// variantColumns, then replaces its option values when options is not nil and
+// refreshes the product aggregates in the same transaction.
+func (r *ProductRepository) writeVariant(ctx context.Context, productID string, options map[string]string, query string, args ...any) (*models.ProductVariant, error) {
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProduct(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+
+	variant, err := scanVariant(tx.QueryRow(ctx, query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	if options != nil {
+		if err := setVariantOptions(ctx, tx, productID, variant.ID.String(), options); err != nil {
+			return nil, err
+		}
+	}
+	if err := refreshVariantOptionsKey(ctx, tx, variant.ID.String()); err != nil {
+		return nil, err
+	}
+	if err := loadVariantOptions(ctx, tx, []*models.ProductVariant{variant}); err != nil {
+		return nil, err
+	}
+
+	if err := refreshProductAggregates(ctx, tx, productID); err != nil {
+		return nil, err
 	}
 
-	// Then insert new variants
-	return r.CreateProductVariants(ctx, productID, variants)
+	return variant, tx.Commit(ctx)
 }
 
-func (r *ProductRepository) ToggleVariantActive(ctx context.Context, variantID string, updatedBy *string) (*models.ProductVariant, error) {
-	query := `
-		UPDATE product_variants
-		SET is_active = NOT is_active, updated_by = $2, updated_at = now()
-		WHERE id = $1
-		RETURNING id, product_id, size, color, stock, price, image, created_by, created_at, updated_by, updated_at, is_active, is_deleted
-	`
+// variantProductID returns the product a variant belongs to.
+func (r *ProductRepository) variantProductID(ctx context.Context, variantID string) (string, error) {
+	var productID string
+	err := r.DB.QueryRow(ctx, "SELECT product_id::text FROM product_variants WHERE id = $1", variantID).Scan(&productID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrVariantNotFound
+	}
+	return productID, err
+}
 
-	var variant models.ProductVariant
-	var createdByUUID *string
-	err := r.DB.QueryRow(ctx, query, variantID, updatedBy).Scan(
-		&variant.ID, &variant.ProductID, &variant.Size, &variant.Color, &variant.Stock, &variant.Price, &variant.Image,
-		&createdByUUID, &variant.CreatedAt, &variant.UpdatedBy, &variant.UpdatedAt, &variant.IsActive, &variant.IsDeleted,
-	)
+// CreateVariant adds a variant to a product that is not deleted.
+func (r *ProductRepository) CreateVariant(ctx context.Context, productID string, input VariantInput, createdBy *string) (*models.ProductVariant, error) {
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	return &variant, nil
+	if err := lockProduct(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+
+	variants, err := r.insertVariants(ctx, tx, productID, []VariantInput{input}, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := refreshProductAggregates(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+
+	return &variants[0], tx.Commit(ctx)
 }
 
-func (r *ProductRepository) SoftDeleteVariant(ctx context.Context, variantID string, updatedBy *string) (*models.ProductVariant, error) {
+// UpdateVariant changes the given fields of a variant that belongs to productID.
+func (r *ProductRepository) UpdateVariant(ctx context.Context, productID, variantID string, update VariantUpdate, updatedBy *string) (*models.ProductVariant, error) {
 	query := `
 		UPDATE product_variants
-		SET is_deleted = true, updated_by = $2, updated_at = now()
+		SET size = COALESCE($3, size),
+			color = COALESCE($4, color),
+			stock = COALESCE($5, stock),
+			price = COALESCE($6, price),
+			image = COALESCE($7, image),
+			sku = COALESCE($9, sku),
+			barcode = CASE WHEN $10::text IS NULL THEN barcode ELSE NULLIF($10::text, '') END,
+			updated_by = $8,
+			updated_at = now()
+		WHERE id = $1 AND product_id = $2 AND is_deleted = false
+		RETURNING ` + variantColumns
+
+	variant, err := r.writeVariant(ctx, productID, update.Options, query, variantID, productID, update.Size, update.Color, update.Stock, update.Price, update.Image, updatedBy, update.SKU, update.Barcode)
+	if errors.Is(err, ErrProductNotFound) {
+		return nil, ErrVariantNotFound
+	}
+	return variant, err
+}
+
+// SoftDeleteProductVariant soft-deletes a variant that belongs to productID.
+func (r *ProductRepository) SoftDeleteProductVariant(ctx context.Context, productID, variantID string, updatedBy *string) (*models.ProductVariant, error) {
+	query := `
+		UPDATE product_variants
+		SET is_deleted = true, updated_by = $3, updated_at = now()
+		WHERE id = $1 AND product_id = $2 AND is_deleted = false
+		RETURNING ` + variantColumns
+
+	variant, err := r.writeVariant(ctx, productID, nil, query, variantID, productID, updatedBy)
+	if errors.Is(err, ErrProductNotFound) {
+		return nil, ErrVariantNotFound
+	}
+	return variant, err
+}
+
+func (r *ProductRepository) ToggleVariantActive(ctx context.Context, variantID string, updatedBy *string) (*models.ProductVariant, error) {
+	productID, err := r.variantProductID(ctx, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE product_variants
+		SET is_active = NOT is_active, updated_by = $2, updated_at = now()
 		WHERE id = $1
-		RETURNING id, product_id, size, color, stock, price, image, created_by, created_at, updated_by, updated_at, is_active, is_deleted
-	`
+		RETURNING ` + variantColumns
 
-	var variant models.ProductVariant
-	var createdByUUID *string
-	err := r.DB.QueryRow(ctx, query, variantID, updatedBy).Scan(
-		&variant.ID, &variant.ProductID, &variant.Size, &variant.Color, &variant.Stock, &variant.Price, &variant.Image,
-		&createdByUUID, &variant.CreatedAt, &variant.UpdatedBy, &variant.UpdatedAt, &variant.IsActive, &variant.IsDeleted,
-	)
+	return r.writeVariant(ctx, productID, nil, query, variantID, updatedBy)
+}
+
+func (r *ProductRepository) SoftDeleteVariant(ctx context.Context, variantID string, updatedBy *string) (*models.ProductVariant, error) {
+	productID, err := r.variantProductID(ctx, variantID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &variant, nil
+	query := `
+		UPDATE product_variants
+		SET is_deleted = true, updated_by = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING ` + variantColumns
+
+	return r.writeVariant(ctx, productID, nil, query, variantID, updatedBy)
 }
@@ -0,0 +1,261 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDB connects to the migrated database in TEST_DATABASE_URL, and skips the
+// test when it is not set.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// createTestProduct creates a product with variants in a new category and
+// removes both when the test ends.
+func createTestProduct(t *testing.T, repo *ProductRepository, variants []VariantInput) *models.Product {
+	t.Helper()
+	ctx := context.Background()
+	name := "test-" + uuid.NewString()
+
+	var categoryID string
+	if err := repo.DB.QueryRow(ctx, "INSERT INTO categories (name) VALUES ($1) RETURNING id::text", name).Scan(&categoryID); err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+
+	product, err := repo.CreateProduct(ctx, ProductInput{Name: name, CategoryName: name, Variants: variants}, nil)
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for _, query := range []string{
+			"DELETE FROM variant_option_values WHERE variant_id IN (SELECT id FROM product_variants WHERE product_id = $1)",
+			"DELETE FROM product_variants WHERE product_id = $1",
+			"DELETE FROM product_attributes WHERE product_id = $1",
+			"DELETE FROM products WHERE id = $1",
+		} {
+			if _, err := repo.DB.Exec(ctx, query, product.ID); err != nil {
+				t.Errorf("clean up: %v", err)
+			}
+		}
+		if _, err := repo.DB.Exec(ctx, "DELETE FROM categories WHERE id = $1", categoryID); err != nil {
+			t.Errorf("clean up: %v", err)
+		}
+	})
+
+	return product
+}
+
+func TestUpdateProductSyncsVariants(t *testing.T) {
+	db := testDB(t)
+	repo := NewProductRepository(db, "", nil)
+
+	tests := []struct {
+		name   string
+		before []VariantInput
+		// after builds the variants of the update from the created ones.
+		after func(created []models.ProductVariant) []VariantInput
+		want  []string
+		// keep is the size and color of a created variant that must keep its ID.
+		keep [2]string
+	}{
+		{
+			name:   "adds a variant",
+			before: []VariantInput{{Size: "M", Color: "Black", Stock: 1, Price: 10}},
+			after: func([]models.ProductVariant) []VariantInput {
+				return []VariantInput{{Size: "M", Color: "Black", Stock: 1, Price: 10}, {Size: "L", Color: "Black", Stock: 2, Price: 12}}
+			},
+			want: []string{"L/Black", "M/Black"},
+			keep: [2]string{"M", "Black"},
+		},
+		{
+			name:   "replaces every variant",
+			before: []VariantInput{{Size: "M", Color: "Black", Stock: 1, Price: 10}},
+			after: func([]models.ProductVariant) []VariantInput {
+				return []VariantInput{{Size: "L", Color: "White", Stock: 2, Price: 12}}
+			},
+			want: []string{"L/White"},
+		},
+		{
+			name:   "moves a variant onto the options of a dropped one",
+			before: []VariantInput{{Size: "M", Color: "Black", Stock: 1, Price: 10}, {Size: "L", Color: "Black", Stock: 2, Price: 12}},
+			after: func(created []models.ProductVariant) []VariantInput {
+				id := variantWith(t, created, "M", "Black").ID.String()
+				return []VariantInput{{ID: &id, Size: "L", Color: "Black", Stock: 3, Price: 11}}
+			},
+			want: []string{"L/Black"},
+			keep: [2]string{"M", "Black"},
+		},
+		{
+			name:   "swaps the options of two variants",
+			before: []VariantInput{{Size: "M", Color: "Black", Stock: 1, Price: 10}, {Size: "L", Color: "Black", Stock: 2, Price: 12}},
+			after: func(created []models.ProductVariant) []VariantInput {
+				m := variantWith(t, created, "M", "Black").ID.String()
+				l := variantWith(t, created, "L", "Black").ID.String()
+				return []VariantInput{{ID: &m, Size: "L", Color: "Black", Stock: 1, Price: 10}, {ID: &l, Size: "M", Color: "Black", Stock: 2, Price: 12}}
+			},
+			want: []string{"L/Black", "M/Black"},
+			keep: [2]string{"M", "Black"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			created := createTestProduct(t, repo, tt.before)
+
+			updated, err := repo.UpdateProduct(ctx, created.ID.String(), ProductInput{
+				Name:         created.Name,
+				Slug:         created.Slug,
+				CategoryName: created.Name,
+				Variants:     tt.after(created.Variants),
+			}, nil)
+			if err != nil {
+				t.Fatalf("UpdateProduct: %v", err)
+			}
+
+			var got []string
+			stock := 0
+			for _, variant := range updated.Variants {
+				got = append(got, variant.Size+"/"+variant.Color)
+				stock += variant.Stock
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("variants = %v, want %v", got, tt.want)
+			}
+			if updated.TotalStock != stock {
+				t.Errorf("total_stock = %d, want %d", updated.TotalStock, stock)
+			}
+
+			if tt.keep != [2]string{} {
+				id := variantWith(t, created.Variants, tt.keep[0], tt.keep[1]).ID
+				if !slices.ContainsFunc(updated.Variants, func(v models.ProductVariant) bool { return v.ID == id }) {
+					t.Errorf("variant %s was not kept", id)
+				}
+			}
+		})
+	}
+}
+
+// variantWith returns the variant with the given size and color.
+func variantWith(t *testing.T, variants []models.ProductVariant, size, color string) models.ProductVariant {
+	t.Helper()
+	for _, variant := range variants {
+		if variant.Size == size && variant.Color == color {
+			return variant
+		}
+	}
+	t.Fatalf("no %s/%s variant", size, color)
+	return models.ProductVariant{}
+}
+
+func TestGetAllProductsLoadsDetailsPerProduct(t *testing.T) {
+	db := testDB(t)
+	repo := NewProductRepository(db, "", nil)
+	ctx := context.Background()
+
+	shirt := createTestProduct(t, repo, []VariantInput{{Size: "M", Color: "Black", Stock: 1, Price: 10}, {Size: "L", Color: "Black", Stock: 1, Price: 10}})
+	scarf := createTestProduct(t, repo, nil)
+	if err := setProductAttributes(ctx, db, shirt.ID.String(), map[string]string{"fabric": "cotton"}); err != nil {
+		t.Fatalf("setProductAttributes: %v", err)
+	}
+
+	page, err := repo.GetAllProducts(ctx, Pagination{Page: 1, Limit: 10}, ProductFilter{Categories: []string{shirt.Name, scarf.Name}}, nil)
+	if err != nil {
+		t.Fatalf("GetAllProducts: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("GetAllProducts returned %d products, want 2", len(page.Items))
+	}
+
+	tests := []struct {
+		product        *models.Product
+		wantVariants   int
+		wantAttributes map[string]string
+	}{
+		{shirt, 2, map[string]string{"fabric": "cotton"}},
+		{scarf, 0, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		i := slices.IndexFunc(page.Items, func(p models.Product) bool { return p.ID == tt.product.ID })
+		if i < 0 {
+			t.Fatalf("product %s is missing", tt.product.Name)
+		}
+		got := page.Items[i]
+		if len(got.Variants) != tt.wantVariants || got.Variants == nil {
+			t.Errorf("%s: variants = %v, want %d", got.Name, got.Variants, tt.wantVariants)
+		}
+		for _, variant := range got.Variants {
+			if variant.ProductID != got.ID.String() {
+				t.Errorf("%s: variant %+v belongs to another product", got.Name, variant)
+			}
+		}
+		if len(got.Attributes) != len(tt.wantAttributes) || got.Attributes["fabric"] != tt.wantAttributes["fabric"] {
+			t.Errorf("%s: attributes = %v, want %v", got.Name, got.Attributes, tt.wantAttributes)
+		}
+		if len(got.Breadcrumbs) != 1 || got.Breadcrumbs[0].Name != got.Name {
+			t.Errorf("%s: breadcrumbs = %v, want its own category", got.Name, got.Breadcrumbs)
+		}
+	}
+}
+
+func TestLookupCategoryAndBrandIgnoresCase(t *testing.T) {
+	db := testDB(t)
+	repo := NewProductRepository(db, "", nil)
+	ctx := context.Background()
+
+	product := createTestProduct(t, repo, nil)
+	brand := "Brand " + uuid.NewString()
+	var brandID string
+	if err := db.QueryRow(ctx, "INSERT INTO brands (name) VALUES ($1) RETURNING id::text", brand).Scan(&brandID); err != nil {
+		t.Fatalf("create brand: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(ctx, "DELETE FROM brands WHERE id = $1", brandID) })
+
+	tests := []struct {
+		name     string
+		category string
+		brand    string
+		wantErr  error
+	}{
+		{"same case", product.Name, brand, nil},
+		{"other case", strings.ToUpper(product.Name), strings.ToLower(brand), nil},
+		{"unknown category", "missing-" + product.Name, brand, ErrCategoryNotFound},
+		{"unknown brand", product.Name, "missing " + brand, ErrBrandNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categoryID, gotBrandID, err := lookupCategoryAndBrand(ctx, db, tt.category, tt.brand)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("lookupCategoryAndBrand error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (categoryID != product.CategoryID || *gotBrandID != brandID) {
+				t.Errorf("lookupCategoryAndBrand = %s, %s, want %s, %s", categoryID, *gotBrandID, product.CategoryID, brandID)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import "context"
+
+// searchConfig is the text search configuration used to index and query products.
+const searchConfig = "english"
+
+// productSearchVector computes products.search_vector for the product aliased p.
+// Keep it in sync with the backfill in migration 000018_product_search.
+const productSearchVector = `
+	setweight(to_tsvector('` + searchConfig + `', COALESCE(p.name, '')), 'A') ||
+	setweight(to_tsvector('` + searchConfig + `', COALESCE((SELECT b.name FROM brands b WHERE b.id = p.brand_id), '')), 'B') ||
+	setweight(to_tsvector('` + searchConfig + `', COALESCE((SELECT c.name FROM categories c WHERE c.id = p.category_id), '')), 'B') ||
+	setweight(to_tsvector('` + searchConfig + `', COALESCE(p.description, '')), 'C')`
+
+// ts_headline options that mark matched words with <mark>: the whole name is
+// highlighted, the description is cut down to the fragments around the matches.
+const (
+	nameHighlightOptions        = `StartSel=<mark>, StopSel=</mark>, HighlightAll=true`
+	descriptionHighlightOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+)
+
+// refreshProductSearch recomputes the search document of the products matching
+// where, e.g. "p.brand_id = $1" after a brand is renamed.
+func refreshProductSearch(ctx context.Context, q querier, where string, arg any) error {
+	_, err := q.Exec(ctx, "UPDATE products p SET search_vector = "+productSearchVector+" WHERE "+where, arg)
+	return err
+}
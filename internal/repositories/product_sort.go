@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidSort = errors.New("sort must be one of relevance, newest, price_asc, price_desc, name, best_selling or top_rated")
+	// ErrRelevanceNeedsSearch is returned when products are sorted by relevance without a search.
+	ErrRelevanceNeedsSearch = errors.New("sorting by relevance requires a search")
+)
+
+// Sort keys of product listings.
+const (
+	SortRelevance   = "relevance"
+	SortNewest      = "newest"
+	SortPriceAsc    = "price_asc"
+	SortPriceDesc   = "price_desc"
+	SortName        = "name"
+	SortBestSelling = "best_selling"
+	SortTopRated    = "top_rated"
+)
+
+// productSortOrders maps each sort key to its ORDER BY terms. Relevance needs the
+// search query joined as search(query), see GetAllProducts.
+var productSortOrders = map[string]string{
+	SortRelevance:   `ts_rank_cd(p.search_vector, search.query) DESC`,
+	SortNewest:      `p.created_at DESC`,
+	SortPriceAsc:    `p.min_price ASC`,
+	SortPriceDesc:   `p.min_price DESC`,
+	SortName:        `lower(p.name) ASC`,
+	SortBestSelling: `p.sold_count DESC`,
+	SortTopRated:    `p.rating_avg DESC, p.rating_count DESC`,
+}
+
+// ParseProductSort reads sort keys given as repeated values, comma-separated
+// values or both, e.g. "price_asc,newest". The first key sorts first; repeated
+// keys are dropped.
+func ParseProductSort(values []string) ([]string, error) {
+	var keys []string
+	for _, value := range values {
+		for _, key := range strings.Split(value, ",") {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if key == "" || slices.Contains(keys, key) {
+				continue
+			}
+			if _, ok := productSortOrders[key]; !ok {
+				return nil, ErrInvalidSort
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// productOrderBy returns the ORDER BY terms for the sort keys, or for the default
+// order when there are none: relevance for searches and newest otherwise. The
+// product ID breaks ties, so pages neither skip nor repeat products.
+func productOrderBy(keys []string, searching bool) (string, error) {
+	if len(keys) == 0 {
+		keys = []string{SortNewest}
+		if searching {
+			keys = []string{SortRelevance, SortNewest}
+		}
+	}
+
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		order, ok := productSortOrders[key]
+		if !ok {
+			return "", ErrInvalidSort
+		}
+		if key == SortRelevance && !searching {
+			return "", ErrRelevanceNeedsSearch
+		}
+		terms[i] = order
+	}
+	return strings.Join(terms, ", ") + `, p.id DESC`, nil
+}
+
+// isNewestFirst reports whether the sort keys order products by (created_at, id)
+// descending, the order Cursor positions are taken in.
+func isNewestFirst(keys []string, searching bool) bool {
+	if len(keys) == 0 {
+		return !searching
+	}
+	return len(keys) == 1 && keys[0] == SortNewest
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseProductSort(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+		err    error
+	}{
+		{"none", nil, nil, nil},
+		{"single", []string{"newest"}, []string{SortNewest}, nil},
+		{"comma-separated", []string{"price_asc,newest"}, []string{SortPriceAsc, SortNewest}, nil},
+		{"repeated values", []string{"name", "price_desc"}, []string{SortName, SortPriceDesc}, nil},
+		{"case and spaces", []string{" Price_Desc , NAME "}, []string{SortPriceDesc, SortName}, nil},
+		{"duplicates dropped", []string{"newest,name", "newest"}, []string{SortNewest, SortName}, nil},
+		{"empty keys skipped", []string{",,newest,"}, []string{SortNewest}, nil},
+		{"best selling and top rated", []string{"best_selling,top_rated"}, []string{SortBestSelling, SortTopRated}, nil},
+		{"unknown key", []string{"newest,cheapest"}, nil, ErrInvalidSort},
+		{"sql", []string{"p.id; DROP TABLE products"}, nil, ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProductSort(tt.values)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseProductSort(%q) error = %v, want %v", tt.values, err, tt.err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseProductSort(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductOrderBy(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		searching bool
+		want      string
+		err       error
+	}{
+		{"default", nil, false, `p.created_at DESC, p.id DESC`, nil},
+		{"default when searching", nil, true, `ts_rank_cd(p.search_vector, search.query) DESC, p.created_at DESC, p.id DESC`, nil},
+		{"several keys", []string{SortPriceAsc, SortName}, false, `p.min_price ASC, lower(p.name) ASC, p.id DESC`, nil},
+		{"best selling", []string{SortBestSelling}, false, `p.sold_count DESC, p.id DESC`, nil},
+		{"top rated", []string{SortTopRated}, false, `p.rating_avg DESC, p.rating_count DESC, p.id DESC`, nil},
+		{"relevance when searching", []string{SortRelevance}, true, `ts_rank_cd(p.search_vector, search.query) DESC, p.id DESC`, nil},
+		{"relevance without a search", []string{SortRelevance}, false, "", ErrRelevanceNeedsSearch},
+		{"relevance after another key without a search", []string{SortNewest, SortRelevance}, false, "", ErrRelevanceNeedsSearch},
+		{"key outside the whitelist", []string{"p.id; DROP TABLE products"}, false, "", ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := productOrderBy(tt.keys, tt.searching)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("productOrderBy(%q, %t) error = %v, want %v", tt.keys, tt.searching, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("productOrderBy(%q, %t) = %q, want %q", tt.keys, tt.searching, got, tt.want)
+			}
+		})
+	}
+}
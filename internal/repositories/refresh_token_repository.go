@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenUsed     = errors.New("refresh token already used or revoked")
+)
+
+type RefreshTokenRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) *RefreshTokenRepository {
+	return &RefreshTokenRepository{DB: db}
+}
+
+// CreateRefreshToken stores the first token of a session. mfa records whether the
+// session was authenticated with a second factor.
+func (r *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID string, familyID uuid.UUID, tokenHash string, expiresAt time.Time, userAgent, ipAddress string, mfa bool) (*models.RefreshToken, error) {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, user_agent, ip_address, mfa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, user_agent, ip_address, mfa, created_at
+	`
+
+	var token models.RefreshToken
+	err := r.DB.QueryRow(ctx, query, userID, familyID, tokenHash, expiresAt, userAgent, ipAddress, mfa).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt,
+		&token.UserAgent, &token.IPAddress, &token.MFA, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *RefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, user_agent, ip_address, mfa, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token models.RefreshToken
+	err := r.DB.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt,
+		&token.UserAgent, &token.IPAddress, &token.MFA, &token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken marks the current token as used and stores its replacement in
+// the same family. It returns ErrRefreshTokenUsed if the token was already used or
+// revoked, which callers must treat as token reuse.
+func (r *RefreshTokenRepository) RotateRefreshToken(ctx context.Context, current *models.RefreshToken, newTokenHash string, expiresAt time.Time, userAgent, ipAddress string) (*models.RefreshToken, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrRefreshTokenUsed
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, user_agent, ip_address, mfa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, user_agent, ip_address, mfa, created_at
+	`
+
+	var token models.RefreshToken
+	err = tx.QueryRow(ctx, query, current.UserID, current.FamilyID, newTokenHash, expiresAt, userAgent, ipAddress, current.MFA).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt,
+		&token.UserAgent, &token.IPAddress, &token.MFA, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RevokeFamily revokes every token issued for one login session.
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	return err
+}
+
+// RevokeAllForUser revokes every refresh token of a user, ending all their sessions.
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
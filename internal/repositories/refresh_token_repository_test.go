@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// createTestUser creates a customer and removes it, with its tokens, when the
+// test ends.
+func createTestUser(t *testing.T, repo *UserRepository) *models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := repo.CreateUser(ctx, "test-"+uuid.NewString()+"@example.com", "password123", "customer")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for _, query := range []string{
+			"DELETE FROM refresh_tokens WHERE user_id = $1",
+			"DELETE FROM password_reset_tokens WHERE user_id = $1",
+			"DELETE FROM users WHERE id = $1",
+		} {
+			if _, err := repo.DB.Exec(ctx, query, user.ID); err != nil {
+				t.Errorf("clean up: %v", err)
+			}
+		}
+	})
+
+	return user
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := testDB(t)
+	repo := NewRefreshTokenRepository(db)
+	user := createTestUser(t, NewUserRepository(db))
+	expiresAt := time.Now().UTC().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		// prepare changes the first token of a new session before it is rotated.
+		prepare func(ctx context.Context, first *models.RefreshToken) error
+		wantErr error
+	}{
+		{
+			name:    "unused token",
+			prepare: func(context.Context, *models.RefreshToken) error { return nil },
+		},
+		{
+			name: "token used before",
+			prepare: func(ctx context.Context, first *models.RefreshToken) error {
+				_, err := repo.RotateRefreshToken(ctx, first, "hash-"+uuid.NewString(), expiresAt, "", "")
+				return err
+			},
+			wantErr: ErrRefreshTokenUsed,
+		},
+		{
+			name: "revoked session",
+			prepare: func(ctx context.Context, first *models.RefreshToken) error {
+				return repo.RevokeFamily(ctx, first.FamilyID)
+			},
+			wantErr: ErrRefreshTokenUsed,
+		},
+		{
+			name: "logged out everywhere",
+			prepare: func(ctx context.Context, first *models.RefreshToken) error {
+				return repo.RevokeAllForUser(ctx, user.ID.String())
+			},
+			wantErr: ErrRefreshTokenUsed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			first, err := repo.CreateRefreshToken(ctx, user.ID.String(), uuid.New(), "hash-"+uuid.NewString(), expiresAt, "test", "192.0.2.1", true)
+			if err != nil {
+				t.Fatalf("CreateRefreshToken: %v", err)
+			}
+			if err := tt.prepare(ctx, first); err != nil {
+				t.Fatalf("prepare: %v", err)
+			}
+
+			next, err := repo.RotateRefreshToken(ctx, first, "hash-"+uuid.NewString(), expiresAt, "test", "192.0.2.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotateRefreshToken error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if next.FamilyID != first.FamilyID || !next.MFA {
+				t.Errorf("rotated token = %+v, want the session and second factor of %+v", next, first)
+			}
+			stored, err := repo.GetRefreshTokenByHash(ctx, first.TokenHash)
+			if err != nil {
+				t.Fatalf("GetRefreshTokenByHash: %v", err)
+			}
+			if stored.UsedAt == nil {
+				t.Error("rotated token was not marked as used")
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenOnlyOnceConcurrently(t *testing.T) {
+	db := testDB(t)
+	repo := NewRefreshTokenRepository(db)
+	user := createTestUser(t, NewUserRepository(db))
+	ctx := context.Background()
+	expiresAt := time.Now().UTC().Add(time.Hour)
+
+	first, err := repo.CreateRefreshToken(ctx, user.ID.String(), uuid.New(), "hash-"+uuid.NewString(), expiresAt, "", "", false)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	const attempts = 5
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = repo.RotateRefreshToken(ctx, first, "hash-"+uuid.NewString(), expiresAt, "", "")
+		}()
+	}
+	wg.Wait()
+
+	rotated := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			rotated++
+		case !errors.Is(err, ErrRefreshTokenUsed):
+			t.Errorf("RotateRefreshToken: %v", err)
+		}
+	}
+	if rotated != 1 {
+		t.Errorf("token was rotated %d times, want once", rotated)
+	}
+}
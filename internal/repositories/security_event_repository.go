@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"clothes-shop-api/internal/models"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SecurityEventRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewSecurityEventRepository(db *pgxpool.Pool) *SecurityEventRepository {
+	return &SecurityEventRepository{DB: db}
+}
+
+func (r *SecurityEventRepository) CreateEvent(ctx context.Context, event models.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (event_type, user_id, email, ip_address, details, actor_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.DB.Exec(ctx, query, event.EventType, event.UserID, event.Email, event.IPAddress, event.Details, event.ActorID)
+	return err
+}
+
+// GetAllEvents returns a page of the security events, newest first, optionally
+// filtered by event type and by email.
+func (r *SecurityEventRepository) GetAllEvents(ctx context.Context, pagination Pagination, eventType, email *string) (Page[models.SecurityEvent], error) {
+	where := `1 = 1`
+	args := sqlArgs{}
+
+	if eventType != nil {
+		where += ` AND event_type = ` + args.add(*eventType)
+	}
+
+	if email != nil {
+		where += ` AND email ILIKE ` + args.add("%"+*email+"%")
+	}
+
+	var total int
+	if err := r.DB.QueryRow(ctx, `SELECT count(*) FROM security_events WHERE `+where, args...).Scan(&total); err != nil {
+		return Page[models.SecurityEvent]{}, err
+	}
+
+	query := `
+		SELECT id, event_type, user_id, email, ip_address, details, actor_id, created_at
+		FROM security_events
+		WHERE ` + where + pagination.keyset(&args, "") + `
+		ORDER BY created_at DESC, id DESC` + pagination.limit(&args)
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return Page[models.SecurityEvent]{}, err
+	}
+	defer rows.Close()
+
+	var events []models.SecurityEvent
+	for rows.Next() {
+		var event models.SecurityEvent
+		err := rows.Scan(&event.ID, &event.EventType, &event.UserID, &event.Email, &event.IPAddress, &event.Details, &event.ActorID, &event.CreatedAt)
+		if err != nil {
+			return Page[models.SecurityEvent]{}, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[models.SecurityEvent]{}, err
+	}
+
+	return newPage(events, total, pagination, func(event models.SecurityEvent) Cursor {
+		return Cursor{CreatedAt: event.CreatedAt, ID: event.ID}
+	}), nil
+}
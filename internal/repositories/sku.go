@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultSKUPattern is used when the product repository has no SKU pattern.
+const defaultSKUPattern = "{product}-{color}-{size}"
+
+// ValidSKU matches the upper-cased SKUs accepted from clients.
+var ValidSKU = regexp.MustCompile(`^[A-Z0-9]+([._-][A-Z0-9]+)*$`)
+
+var (
+	skuPlaceholders = regexp.MustCompile(`\{[a-z]+\}`)
+	skuInvalidChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	skuSeparatorRun = regexp.MustCompile(`([._-])[._-]+`)
+)
+
+// SKUFields are the values substituted into a SKU pattern.
+type SKUFields struct {
+	Product  string
+	Brand    string
+	Category string
+	Size     string
+	Color    string
+}
+
+// FormatSKU fills in the {product}, {brand}, {category}, {size} and {color}
+// placeholders of pattern, e.g. "{product}-{color}-{size}" gives
+// "AO-THUN-NIKE-SPORT-DEN-M". Unknown placeholders and empty values are dropped.
+func FormatSKU(pattern string, fields SKUFields) string {
+	values := map[string]string{
+		"{product}":  fields.Product,
+		"{brand}":    fields.Brand,
+		"{category}": fields.Category,
+		"{size}":     fields.Size,
+		"{color}":    fields.Color,
+	}
+
+	sku := skuPlaceholders.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		return slugPart(values[placeholder])
+	})
+	sku = skuSeparatorRun.ReplaceAllString(skuInvalidChars.ReplaceAllString(sku, "-"), "$1")
+	sku = strings.ToUpper(strings.Trim(sku, "._-"))
+	if sku == "" {
+		return "SKU"
+	}
+	return sku
+}
+
+// ValidBarcode reports whether code is an EAN-8, UPC-A or EAN-13 barcode with a
+// correct check digit.
+func ValidBarcode(code string) bool {
+	if len(code) != 8 && len(code) != 12 && len(code) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+		if i == len(code)-1 {
+			continue
+		}
+
+		digit := int(code[i] - '0')
+		// Weights alternate 3, 1, 3, ... from the digit left of the check digit
+		if (len(code)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
+
+// uniqueVariantSKU returns base, or base with the lowest free numeric suffix,
+// that no variant uses. SKUs of deleted variants stay reserved. q must be a
+// transaction, which holds the SKU until it commits.
+func uniqueVariantSKU(ctx context.Context, q querier, base string) (string, error) {
+	if err := lockSuffixedName(ctx, q, "product_variants.sku", base); err != nil {
+		return "", err
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT sku FROM product_variants
+		WHERE sku = $1 OR sku LIKE $1 || '-%'
+	`, base)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var sku string
+		if err := rows.Scan(&sku); err != nil {
+			return "", err
+		}
+		taken[sku] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	sku := base
+	for n := 2; taken[sku]; n++ {
+		sku = base + "-" + strconv.Itoa(n)
+	}
+	return sku, nil
+}
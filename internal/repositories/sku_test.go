@@ -0,0 +1,55 @@
+package repositories
+
+import "testing"
+
+func TestFormatSKU(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		fields  SKUFields
+		want    string
+	}{
+		{"default pattern", defaultSKUPattern, SKUFields{Product: "Áo Thun Nike Sport", Color: "Đen", Size: "M"}, "AO-THUN-NIKE-SPORT-DEN-M"},
+		{"empty value", defaultSKUPattern, SKUFields{Product: "Áo Thun", Size: "M"}, "AO-THUN-M"},
+		{"dot separator", "{category}.{size}", SKUFields{Category: "Quần Jeans", Size: "XL"}, "QUAN-JEANS.XL"},
+		{"unknown placeholder and invalid characters", "{brand}/{sku}-{size}", SKUFields{Brand: "Nike", Size: "S"}, "NIKE-S"},
+		{"literal prefix", "SHOP_{brand}", SKUFields{Brand: "Levi's"}, "SHOP_LEVI-S"},
+		{"nothing left", "{color}-{size}", SKUFields{}, "SKU"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatSKU(tt.pattern, tt.fields)
+			if got != tt.want {
+				t.Errorf("FormatSKU(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+			if !ValidSKU.MatchString(got) {
+				t.Errorf("FormatSKU(%q) = %q, which ValidSKU rejects", tt.pattern, got)
+			}
+		})
+	}
+}
+
+func TestValidBarcode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"4006381333931", true},  // EAN-13
+		{"036000291452", true},   // UPC-A
+		{"96385074", true},       // EAN-8
+		{"4006381333932", false}, // wrong check digit
+		{"036000291453", false},
+		{"96385075", false},
+		{"400638133393", false}, // EAN-13 without its check digit
+		{"4006381333", false},
+		{"40063813339A", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidBarcode(tt.code); got != tt.want {
+			t.Errorf("ValidBarcode(%q) = %t, want %t", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+	numericSuffix  = regexp.MustCompile(`(-[0-9]+)+$`)
+)
+
+// ValidSlug matches the slugs generated by Slugify.
+var ValidSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// querier is implemented by both the pool and transactions.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Slugify turns a name into a lowercase ASCII URL slug, e.g.
+// "Áo Thun Nike Sport" becomes "ao-thun-nike-sport".
+func Slugify(name string) string {
+	if slug := slugPart(name); slug != "" {
+		return slug
+	}
+	return "product"
+}
+
+// slugPart is Slugify without the fallback for names that have no letters or digits.
+func slugPart(name string) string {
+	// Strip diacritics; đ is a separate letter rather than a d with a mark
+	name = strings.NewReplacer("đ", "d", "Đ", "D").Replace(name)
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err == nil {
+		name = stripped
+	}
+
+	return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// lockSuffixedName takes a transaction lock on the names base and base-N of
+// scope, so concurrent transactions cannot both pick the same free suffix. The
+// lock covers every base with the same stem, because "shirt" may pick
+// "shirt-2" while another transaction inserts "shirt-2" itself. It is released
+// when the transaction ends.
+func lockSuffixedName(ctx context.Context, q querier, scope, base string) error {
+	_, err := q.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, scope+":"+numericSuffix.ReplaceAllString(base, ""))
+	return err
+}
+
+// uniqueProductSlug returns base, or base with the lowest free numeric suffix,
+// that no product other than excludeID uses. q must be a transaction, which
+// holds the slug until it commits.
+func uniqueProductSlug(ctx context.Context, q querier, base string, excludeID *string) (string, error) {
+	if err := lockSuffixedName(ctx, q, "products.slug", base); err != nil {
+		return "", err
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT slug FROM products
+		WHERE (slug = $1 OR slug LIKE $1 || '-%') AND ($2::uuid IS NULL OR id <> $2::uuid)
+	`, base, excludeID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", err
+		}
+		taken[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug, nil
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Áo Thun Nike Sport", "ao-thun-nike-sport"},
+		{"Đầm Dự Tiệc", "dam-du-tiec"},
+		{"T-Shirt 2024", "t-shirt-2024"},
+		{"  --Hello,   World!! ", "hello-world"},
+		{"Crème Brûlée", "creme-brulee"},
+		{"!!!", "product"},
+		{"", "product"},
+	}
+
+	for _, tt := range tests {
+		got := Slugify(tt.name)
+		if got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if !ValidSlug.MatchString(got) {
+			t.Errorf("Slugify(%q) = %q, which ValidSlug rejects", tt.name, got)
+		}
+	}
+}
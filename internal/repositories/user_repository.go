@@ -4,12 +4,42 @@ import (
 	"clothes-shop-api/internal/models"
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already registered")
+)
+
+// userColumns is the column list read by scanUser.
+const userColumns = `id, email, password, role, full_name, phone, to_char(date_of_birth, 'YYYY-MM-DD'), preferred_size,
+	email_verified_at, totp_secret, totp_enabled_at, totp_last_counter,
+	created_by, created_at, updated_by, updated_at, is_active, is_deleted`
+
+// UserProfileUpdate holds the profile fields to change; nil fields are left as they are.
+type UserProfileUpdate struct {
+	FullName      *string
+	Phone         *string
+	DateOfBirth   *string
+	PreferredSize *string
+}
+
+// UserFilter narrows GetAllUsers; nil fields are not filtered on.
+type UserFilter struct {
+	Role           *string
+	IsActive       *bool
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	Search         *string
+	IncludeDeleted bool
+}
+
 type UserRepository struct {
 	DB *pgxpool.Pool
 }
@@ -18,48 +48,216 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, email, password, role string) (*models.User, error) {
+// hashPassword returns the bcrypt hash stored in users.password.
+func hashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+// scanUser reads a row selected with userColumns.
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.Role, &user.FullName, &user.Phone, &user.DateOfBirth, &user.PreferredSize,
+		&user.EmailVerifiedAt, &user.TOTPSecret, &user.TOTPEnabledAt, &user.TOTPLastCounter,
+		&user.CreatedBy, &user.CreatedAt, &user.UpdatedBy, &user.UpdatedAt, &user.IsActive, &user.IsDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
 		return nil, err
 	}
+	return &user, nil
+}
 
-	query := `
-		INSERT INTO users (email, password, role)
-		VALUES ($1, $2, $3)
-		RETURNING id, email, password, role, created_at
-	`
+// normalizeEmail returns email as it is stored: trimmed and in lower case, so
+// that an address matches however it is typed.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
 
-	var user models.User
-	err = r.DB.QueryRow(ctx, query, email, string(hashedPassword), role).Scan(
-		&user.ID, &user.Email, &user.Password, &user.Role, &user.CreatedAt,
-	)
+func (r *UserRepository) CreateUser(ctx context.Context, email, password, role string) (*models.User, error) {
+	hashedPassword, err := hashPassword(password)
 	if err != nil {
 		return nil, err
 	}
 
-	return &user, nil
+	query := `
+		INSERT INTO users (email, password, role)
+		VALUES ($1, $2, $3)
+		RETURNING ` + userColumns
+
+	return scanUser(r.DB.QueryRow(ctx, query, normalizeEmail(email), hashedPassword, role))
 }
 
+// GetUserByEmail returns the user with email, which is not case-sensitive.
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, email, password, role, created_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE email = $1
+		WHERE lower(email) = lower($1)
 	`
 
-	var user models.User
-	err := r.DB.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.Password, &user.Role, &user.CreatedAt,
-	)
+	return scanUser(r.DB.QueryRow(ctx, query, normalizeEmail(email)))
+}
+
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+
+	return scanUser(r.DB.QueryRow(ctx, query, id))
+}
+
+// GetAllUsers returns a page of the users that match filter, newest first.
+func (r *UserRepository) GetAllUsers(ctx context.Context, pagination Pagination, filter UserFilter) (Page[models.User], error) {
+	where := `1 = 1`
+	args := sqlArgs{}
+
+	if !filter.IncludeDeleted {
+		where += ` AND is_deleted = false`
+	}
+
+	if filter.Role != nil {
+		where += ` AND role = ` + args.add(*filter.Role)
+	}
+
+	if filter.IsActive != nil {
+		where += ` AND is_active = ` + args.add(*filter.IsActive)
+	}
+
+	if filter.CreatedFrom != nil {
+		where += ` AND created_at >= ` + args.add(*filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		where += ` AND created_at < ` + args.add(*filter.CreatedTo)
+	}
+
+	if filter.Search != nil {
+		search := args.add("%" + *filter.Search + "%")
+		where += ` AND (email ILIKE ` + search + ` OR full_name ILIKE ` + search + `)`
+	}
+
+	var total int
+	if err := r.DB.QueryRow(ctx, `SELECT count(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		return Page[models.User]{}, err
+	}
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE ` + where + pagination.keyset(&args, "") + `
+		ORDER BY created_at DESC, id DESC` + pagination.limit(&args)
+
+	rows, err := r.DB.Query(ctx, query, args...)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New("user not found")
+		return Page[models.User]{}, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return Page[models.User]{}, err
 		}
-		return nil, err
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[models.User]{}, err
 	}
 
-	return &user, nil
+	return newPage(users, total, pagination, func(user models.User) Cursor {
+		return Cursor{CreatedAt: user.CreatedAt, ID: user.ID}
+	}), nil
+}
+
+func (r *UserRepository) UpdateUserRole(ctx context.Context, id, role string, updatedBy *string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET role = $2, updated_by = $3, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + userColumns
+
+	return scanUser(r.DB.QueryRow(ctx, query, id, role, updatedBy))
+}
+
+func (r *UserRepository) ToggleActive(ctx context.Context, id string, updatedBy *string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET is_active = NOT is_active, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + userColumns
+
+	return scanUser(r.DB.QueryRow(ctx, query, id, updatedBy))
+}
+
+// SoftDelete hides the account from listings and blocks sign-in. The row and its
+// email address are kept for order history.
+func (r *UserRepository) SoftDelete(ctx context.Context, id string, updatedBy *string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET is_deleted = true, is_active = false, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + userColumns
+
+	return scanUser(r.DB.QueryRow(ctx, query, id, updatedBy))
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, id string, update UserProfileUpdate) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET full_name = COALESCE($2, full_name),
+			phone = COALESCE($3, phone),
+			date_of_birth = CASE WHEN $4::text IS NULL THEN date_of_birth ELSE NULLIF($4::text, '')::date END,
+			preferred_size = COALESCE($5, preferred_size),
+			updated_by = $1,
+			updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + userColumns
+
+	return scanUser(r.DB.QueryRow(ctx, query, id, update.FullName, update.Phone, update.DateOfBirth, update.PreferredSize))
+}
+
+// Deactivate marks the account inactive; it is kept for order history and can be
+// reactivated by an admin.
+func (r *UserRepository) Deactivate(ctx context.Context, id string, updatedBy *string) (*models.User, error) {
+	query := `
+		UPDATE users
+		SET is_active = false, updated_by = $2, updated_at = now()
+		WHERE id = $1 AND is_deleted = false
+		RETURNING ` + userColumns
+
+	return scanUser(r.DB.QueryRow(ctx, query, id, updatedBy))
+}
+
+// IsEmailTaken reports whether any other account uses email.
+func (r *UserRepository) IsEmailTaken(ctx context.Context, email, exceptUserID string) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1) AND id <> $2)", normalizeEmail(email), exceptUserID).Scan(&exists)
+	return exists, err
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.DB.Exec(ctx, "UPDATE users SET password = $2, updated_at = now() WHERE id = $1", id, hashedPassword)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
 }
 
 func (r *UserRepository) CheckPassword(hashedPassword, password string) bool {
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEmailsAreNotCaseSensitive(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	email := "Test-" + uuid.NewString() + "@Example.com"
+	user, err := repo.CreateUser(ctx, " "+email+" ", "password123", "customer")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(ctx, "DELETE FROM users WHERE id = $1", user.ID) })
+	if user.Email != strings.ToLower(email) {
+		t.Errorf("stored email = %q, want %q", user.Email, strings.ToLower(email))
+	}
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{"as registered", email, nil},
+		{"lower case", strings.ToLower(email), nil},
+		{"upper case with spaces", "  " + strings.ToUpper(email), nil},
+		{"other address", "other-" + email, ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetUserByEmail(ctx, tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetUserByEmail error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.ID != user.ID {
+				t.Errorf("GetUserByEmail = %s, want %s", got.ID, user.ID)
+			}
+
+			taken, err := repo.IsEmailTaken(ctx, tt.email, uuid.NewString())
+			if err != nil {
+				t.Fatalf("IsEmailTaken: %v", err)
+			}
+			if taken != (tt.wantErr == nil) {
+				t.Errorf("IsEmailTaken = %t, want %t", taken, tt.wantErr == nil)
+			}
+		})
+	}
+
+	if _, err := repo.CreateUser(ctx, strings.ToUpper(email), "password123", "customer"); err == nil {
+		t.Error("CreateUser registered the same email in another case")
+	}
+}
@@ -1,37 +1,224 @@
 package routes
 
 import (
+	"clothes-shop-api/internal/auth"
 	"clothes-shop-api/internal/config"
 	"clothes-shop-api/internal/handlers"
+	"clothes-shop-api/internal/mailer"
+	"clothes-shop-api/internal/middleware"
+	"clothes-shop-api/internal/oauth"
 	"clothes-shop-api/internal/repositories"
+	"clothes-shop-api/internal/storage"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine, jwtSecret string) {
+func SetupRoutes(r *gin.Engine, cfg config.Config) {
 	// Initialize repositories
-	productRepo := repositories.NewProductRepository(config.DB)
+	productRepo := repositories.NewProductRepository(config.DB, cfg.SKUPattern, priceBuckets(cfg.PriceBuckets))
 	userRepo := repositories.NewUserRepository(config.DB)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(config.DB)
+	passwordResetRepo := repositories.NewPasswordResetRepository(config.DB)
+	verificationRepo := repositories.NewEmailVerificationRepository(config.DB)
+	securityEventRepo := repositories.NewSecurityEventRepository(config.DB)
+	mfaRepo := repositories.NewMFARepository(config.DB)
+	categoryRepo := repositories.NewCategoryRepository(config.DB)
+	brandRepo := repositories.NewBrandRepository(config.DB)
+	optionTypeRepo := repositories.NewOptionTypeRepository(config.DB)
+	productImageRepo := repositories.NewProductImageRepository(config.DB)
+	apiKeyRepo := repositories.NewAPIKeyRepository(config.DB)
+	oauthRepo := repositories.NewOAuthRepository(config.DB)
+
+	// Initialize services
+	mail := mailer.New(cfg.MailDriver, cfg.MailFrom, cfg.MailDir)
+	store := storage.New(storage.Config{
+		Driver:  cfg.StorageDriver,
+		Dir:     cfg.StorageDir,
+		BaseURL: cfg.StorageBaseURL,
+		S3: storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PublicURL:       cfg.S3PublicURL,
+		},
+	})
+
+	var loginAttemptStore auth.LoginAttemptStore = repositories.NewLoginAttemptRepository(config.DB)
+	if cfg.LoginAttemptStore == "memory" {
+		loginAttemptStore = auth.NewMemoryLoginAttemptStore()
+	}
+	loginGuard := auth.NewLoginGuard(loginAttemptStore, auth.LoginGuardConfig{
+		FreeAttempts:       cfg.LoginFreeAttempts,
+		BaseDelay:          cfg.LoginBaseDelay,
+		MaxDelay:           cfg.LoginMaxDelay,
+		MaxAccountFailures: cfg.LoginMaxAccountFailures,
+		MaxIPFailures:      cfg.LoginMaxIPFailures,
+		LockoutDuration:    cfg.LoginLockoutDuration,
+	})
+
+	var oauthProviders []oauth.Provider
+	if cfg.OIDCIssuerURL != "" && cfg.OIDCClientID != "" {
+		redirectURL := cfg.OIDCRedirectURL
+		if redirectURL == "" {
+			redirectURL = cfg.APIURL + "/auth/oauth/" + cfg.OIDCProviderName + "/callback"
+		}
+		oauthProviders = append(oauthProviders, oauth.NewOIDCProvider(oauth.OIDCConfig{
+			Name:         cfg.OIDCProviderName,
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}))
+	}
 
 	// Initialize handlers
 	productHandler := handlers.NewProductHandler(productRepo)
-	authHandler := handlers.NewAuthHandler(userRepo, jwtSecret)
+	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
+	brandHandler := handlers.NewBrandHandler(brandRepo)
+	optionTypeHandler := handlers.NewOptionTypeHandler(optionTypeRepo)
+	productImageHandler := handlers.NewProductImageHandler(productImageRepo, store, cfg)
+	authHandler := handlers.NewAuthHandler(userRepo, refreshTokenRepo, passwordResetRepo, verificationRepo, securityEventRepo, mfaRepo, oauthRepo, oauthProviders, loginGuard, mail, cfg)
+	profileHandler := handlers.NewProfileHandler(userRepo, refreshTokenRepo, verificationRepo, mail, cfg)
+	userHandler := handlers.NewUserHandler(userRepo, refreshTokenRepo, passwordResetRepo, securityEventRepo, loginGuard, mail, cfg)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo)
 
 	// Auth routes
 	r.POST("/register", authHandler.Register)
 	r.POST("/login", authHandler.Login)
+	r.POST("/auth/login/2fa", authHandler.LoginMFA)
+	r.POST("/auth/refresh", authHandler.Refresh)
+	r.POST("/auth/logout", authHandler.Logout)
+	r.POST("/auth/forgot-password", authHandler.ForgotPassword)
+	r.POST("/auth/reset-password", authHandler.ResetPassword)
+	r.GET("/auth/verify-email", authHandler.VerifyEmail)
+	r.GET("/auth/oauth/:provider/login", authHandler.OAuthLogin)
+	r.GET("/auth/oauth/:provider/callback", authHandler.OAuthCallback)
 
-	// Product routes
+	// Uploaded files are served by the API itself when stored on local disk
+	if cfg.StorageDriver != "s3" {
+		r.Static("/uploads", cfg.StorageDir)
+	}
+
+	// Public product routes
 	r.GET("/products", productHandler.GetAllProducts)
-	r.POST("/products", productHandler.CreateProduct)
-	r.PUT("/products/:id", productHandler.UpdateProduct)
-	r.PATCH("/products/:id/toggle-active", productHandler.ToggleActive)
-	r.DELETE("/products/:id/soft-delete", productHandler.SoftDelete)
+	r.GET("/products/:id", productHandler.GetProduct)
+	r.GET("/products/slug/:slug", productHandler.GetProductBySlug)
+	r.GET("/products/:id/images", productImageHandler.GetProductImages)
+	r.GET("/variants/by-sku/:sku", productHandler.GetVariantBySKU)
+	r.GET("/variants/by-barcode/:code", productHandler.GetVariantByBarcode)
+
+	r.GET("/categories", categoryHandler.GetAllCategories)
+	r.GET("/categories/tree", categoryHandler.GetCategoryTree)
+	r.GET("/categories/:id", categoryHandler.GetCategory)
+	r.GET("/categories/:id/option-types", optionTypeHandler.GetCategoryOptionTypes)
+	r.GET("/brands", brandHandler.GetAllBrands)
+	r.GET("/brands/:id", brandHandler.GetBrand)
+	r.GET("/option-types", optionTypeHandler.GetAllOptionTypes)
+
+	// Routes below require a valid bearer token
+	protected := r.Group("/")
+	protected.Use(middleware.AuthRequired(cfg.JWTSecret, userRepo))
+
+	protected.POST("/auth/resend-verification", authHandler.ResendVerification)
+
+	// Self-service account routes
+	protected.GET("/users/me", profileHandler.GetMe)
+	protected.PATCH("/users/me", profileHandler.UpdateMe)
+
+	// Credential and ownership changes are not allowed with impersonation tokens
+	account := protected.Group("/")
+	account.Use(middleware.DenyImpersonation())
+
+	account.POST("/auth/logout-all", authHandler.LogoutAll)
+	account.POST("/auth/change-password", authHandler.ChangePassword)
+	account.POST("/users/me/email", profileHandler.ChangeEmail)
+	account.POST("/users/me/deactivate", profileHandler.DeactivateMe)
+
+	// Two-factor enrollment stays reachable for roles that must enroll
+	account.POST("/auth/2fa/setup", authHandler.SetupTOTP)
+	account.POST("/auth/2fa/confirm", authHandler.ConfirmTOTP)
+	account.POST("/auth/2fa/disable", authHandler.DisableTOTP)
+	account.POST("/auth/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+	// Routes below also require two-factor authentication for MFA_REQUIRED_ROLES
+	privileged := protected.Group("/")
+	privileged.Use(middleware.RequireMFAForRoles(cfg.MFARequiredRoles))
+
+	// Catalog management routes (staff and admin, or an API key with the catalog:write scope)
+	catalog := r.Group("/")
+	catalog.Use(
+		middleware.AuthRequiredOrAPIKey(cfg.JWTSecret, userRepo, apiKeyRepo),
+		middleware.RequireMFAForRoles(cfg.MFARequiredRoles),
+		middleware.RequirePermission(auth.PermCatalogWrite),
+	)
+
+	// Product routes
+	catalog.POST("/products", productHandler.CreateProduct)
+	catalog.PUT("/products/:id", productHandler.UpdateProduct)
+	catalog.PATCH("/products/:id/toggle-active", productHandler.ToggleActive)
+	catalog.DELETE("/products/:id/soft-delete", productHandler.SoftDelete)
+	catalog.POST("/products/:id/variants", productHandler.CreateVariant)
+	catalog.PATCH("/products/:id/variants/:variantId", productHandler.UpdateVariant)
+	catalog.DELETE("/products/:id/variants/:variantId", productHandler.DeleteVariant)
+	catalog.POST("/products/:id/images", productImageHandler.UploadProductImage)
+	catalog.PUT("/products/:id/images/order", productImageHandler.ReorderProductImages)
+	catalog.DELETE("/products/:id/images/:imageId", productImageHandler.DeleteProductImage)
 
 	// Product variant routes
-	r.PATCH("/product-variants/:id/toggle-active", productHandler.ToggleVariantActive)
-	r.DELETE("/product-variants/:id/soft-delete", productHandler.SoftDeleteVariant)
+	catalog.PATCH("/product-variants/:id/toggle-active", productHandler.ToggleVariantActive)
+	catalog.DELETE("/product-variants/:id/soft-delete", productHandler.SoftDeleteVariant)
+
+	// Category routes
+	catalog.POST("/categories", categoryHandler.CreateCategory)
+	catalog.PUT("/categories/:id", categoryHandler.UpdateCategory)
+	catalog.DELETE("/categories/:id/soft-delete", categoryHandler.SoftDeleteCategory)
+	catalog.PATCH("/categories/:id/restore", categoryHandler.RestoreCategory)
+	catalog.PUT("/categories/:id/option-types", optionTypeHandler.SetCategoryOptionTypes)
+
+	// Brand routes
+	catalog.POST("/brands", brandHandler.CreateBrand)
+	catalog.PUT("/brands/:id", brandHandler.UpdateBrand)
+	catalog.DELETE("/brands/:id/soft-delete", brandHandler.SoftDeleteBrand)
+	catalog.PATCH("/brands/:id/restore", brandHandler.RestoreBrand)
+
+	// Option type routes
+	catalog.POST("/option-types", optionTypeHandler.CreateOptionType)
+	catalog.PUT("/option-types/:id", optionTypeHandler.UpdateOptionType)
+
+	// Admin routes
+	admin := privileged.Group("/admin")
+	admin.Use(middleware.RequirePermission(auth.PermUsersManage))
+
+	admin.GET("/users", userHandler.GetAllUsers)
+	admin.GET("/users/:id", userHandler.GetUser)
+	admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
+	admin.PATCH("/users/:id/toggle-active", userHandler.ToggleUserActive)
+	admin.DELETE("/users/:id/soft-delete", userHandler.SoftDeleteUser)
+	admin.POST("/users/:id/force-password-reset", userHandler.ForcePasswordReset)
+	admin.POST("/users/:id/impersonate", userHandler.ImpersonateUser)
+	admin.POST("/users/:id/unlock", userHandler.UnlockUser)
+	admin.POST("/ips/:ip/unlock", userHandler.UnlockIP)
+	admin.GET("/security-events", userHandler.GetSecurityEvents)
+
+	admin.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+	admin.GET("/api-keys", apiKeyHandler.GetAllAPIKeys)
+	admin.DELETE("/api-keys/:id/soft-delete", apiKeyHandler.RevokeAPIKey)
+}
 
-	r.GET("/categories", productHandler.GetAllCategories)
-	r.GET("/brands", productHandler.GetAllBrands)
+// priceBuckets parses the configured price ranges, skipping invalid ones.
+func priceBuckets(values []string) []repositories.PriceRange {
+	var buckets []repositories.PriceRange
+	for _, value := range values {
+		bucket, ok := repositories.ParsePriceRange(value)
+		if !ok {
+			log.Printf("Ignoring invalid price bucket %q", value)
+			continue
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
 }
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that are empty or would escape the storage root.
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage keeps uploaded files such as product images. Keys are slash-separated
+// relative paths like "products/<id>/<image>.jpg". Implementations must be safe
+// for concurrent use.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of the object stored under key.
+	URL(key string) string
+}
+
+// Config selects and configures the storage backend.
+type Config struct {
+	// Driver is "s3" for an S3-compatible bucket; anything else stores files on disk.
+	Driver string
+	// Dir is the directory the local driver writes to.
+	Dir string
+	// BaseURL is the URL Dir is served under by the local driver.
+	BaseURL string
+	S3      S3Config
+}
+
+// New returns the storage for cfg.Driver: "s3" stores objects in an S3-compatible
+// bucket, anything else writes files to cfg.Dir.
+func New(cfg Config) Storage {
+	switch cfg.Driver {
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	default:
+		return &LocalStorage{Dir: cfg.Dir, BaseURL: cfg.BaseURL}
+	}
+}
+
+// cleanKey validates key and returns it in canonical form.
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,5 @@
+-- Restrict users.role to the fixed set of roles
+UPDATE users SET role = 'customer' WHERE role IS NULL OR role NOT IN ('customer', 'staff', 'admin');
+
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('customer', 'staff', 'admin'));
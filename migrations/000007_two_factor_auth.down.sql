@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS mfa;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_counter;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
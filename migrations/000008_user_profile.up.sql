@@ -0,0 +1,19 @@
+-- Profile fields
+ALTER TABLE users ADD COLUMN full_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN phone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN date_of_birth DATE;
+ALTER TABLE users ADD COLUMN preferred_size TEXT NOT NULL DEFAULT '';
+
+-- BaseModel columns, as on products and product_variants
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN created_by UUID;
+ALTER TABLE users ADD COLUMN updated_by UUID;
+ALTER TABLE users ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE users ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT false;
+
+-- Emails are matched without case and stored in lower case. Existing addresses
+-- are lowered unless that would collide with another account.
+UPDATE users u SET email = lower(btrim(email))
+WHERE email <> lower(btrim(email))
+    AND NOT EXISTS (SELECT 1 FROM users o WHERE o.id <> u.id AND lower(btrim(o.email)) = lower(btrim(u.email)));
+CREATE INDEX idx_users_email_lower ON users (lower(email));
@@ -0,0 +1,18 @@
+-- SEO slugs for products. Existing products get a slug derived from their name;
+-- duplicates are numbered in creation order.
+ALTER TABLE products ADD COLUMN slug TEXT;
+
+WITH base AS (
+    SELECT id, COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')), ''), 'product') AS slug, created_at
+    FROM products
+), numbered AS (
+    SELECT id, slug, row_number() OVER (PARTITION BY slug ORDER BY created_at, id) AS n
+    FROM base
+)
+UPDATE products p
+SET slug = CASE WHEN numbered.n = 1 THEN numbered.slug ELSE numbered.slug || '-' || numbered.n END
+FROM numbered
+WHERE numbered.id = p.id;
+
+ALTER TABLE products ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX idx_products_slug ON products(slug);
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_brands_name;
+DROP INDEX IF EXISTS idx_categories_name;
+
+ALTER TABLE brands DROP COLUMN IF EXISTS is_deleted;
+ALTER TABLE brands DROP COLUMN IF EXISTS is_active;
+ALTER TABLE brands DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE brands DROP COLUMN IF EXISTS created_by;
+ALTER TABLE brands DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE brands DROP COLUMN IF EXISTS created_at;
+ALTER TABLE brands DROP COLUMN IF EXISTS logo_url;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS is_deleted;
+ALTER TABLE categories DROP COLUMN IF EXISTS is_active;
+ALTER TABLE categories DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE categories DROP COLUMN IF EXISTS created_by;
+ALTER TABLE categories DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE categories DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,19 @@
+-- BaseModel columns, as on products and product_variants
+ALTER TABLE categories ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE categories ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE categories ADD COLUMN created_by UUID;
+ALTER TABLE categories ADD COLUMN updated_by UUID;
+ALTER TABLE categories ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE categories ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE brands ADD COLUMN logo_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE brands ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE brands ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE brands ADD COLUMN created_by UUID;
+ALTER TABLE brands ADD COLUMN updated_by UUID;
+ALTER TABLE brands ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE brands ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT false;
+
+-- Names are unique among categories and brands that are not deleted
+CREATE UNIQUE INDEX idx_categories_name ON categories (lower(name)) WHERE is_deleted = false;
+CREATE UNIQUE INDEX idx_brands_name ON brands (lower(name)) WHERE is_deleted = false;
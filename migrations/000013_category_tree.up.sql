@@ -0,0 +1,5 @@
+-- Categories form a tree; parent_id is NULL for top-level categories
+ALTER TABLE categories ADD COLUMN parent_id UUID REFERENCES categories(id);
+ALTER TABLE categories ADD CONSTRAINT chk_categories_parent CHECK (parent_id <> id);
+
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
@@ -0,0 +1,2 @@
+-- The recomputed aggregates are kept; the previous values were inconsistent.
+SELECT 1;
@@ -0,0 +1,12 @@
+-- The price range and stock are now maintained from the active variants by the
+-- repository. Recompute them once, as earlier writes double-counted stock.
+UPDATE products
+SET min_price = COALESCE(v.min_price, 0), max_price = COALESCE(v.max_price, 0), total_stock = COALESCE(v.total_stock, 0)
+FROM products p
+LEFT JOIN (
+    SELECT product_id, min(price) AS min_price, max(price) AS max_price, sum(stock) AS total_stock
+    FROM product_variants
+    WHERE is_active = true AND is_deleted = false
+    GROUP BY product_id
+) v ON v.product_id = p.id
+WHERE products.id = p.id;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_product_variants_options;
+DROP INDEX IF EXISTS idx_product_variants_barcode;
+DROP INDEX IF EXISTS idx_product_variants_sku;
+
+ALTER TABLE product_variants DROP COLUMN IF EXISTS barcode;
+ALTER TABLE product_variants DROP COLUMN IF EXISTS sku;
@@ -0,0 +1,51 @@
+ALTER TABLE product_variants ADD COLUMN sku TEXT;
+ALTER TABLE product_variants ADD COLUMN barcode TEXT;
+
+-- Keep only the oldest variant of each (product, size, color) combination
+UPDATE product_variants
+SET is_deleted = true, updated_at = now()
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY product_id, lower(size), lower(color) ORDER BY created_at, id) AS n
+        FROM product_variants
+        WHERE is_deleted = false
+    ) duplicates
+    WHERE n > 1
+);
+
+UPDATE products
+SET min_price = COALESCE(v.min_price, 0), max_price = COALESCE(v.max_price, 0), total_stock = COALESCE(v.total_stock, 0)
+FROM products p
+LEFT JOIN (
+    SELECT product_id, min(price) AS min_price, max(price) AS max_price, sum(stock) AS total_stock
+    FROM product_variants
+    WHERE is_active = true AND is_deleted = false
+    GROUP BY product_id
+) v ON v.product_id = p.id
+WHERE products.id = p.id;
+
+-- Backfill SKUs with the default pattern {product}-{color}-{size}
+UPDATE product_variants
+SET sku = s.sku
+FROM (
+    SELECT id, CASE WHEN n = 1 THEN base ELSE base || '-' || n END AS sku
+    FROM (
+        SELECT id, base, row_number() OVER (PARTITION BY base ORDER BY created_at, id) AS n
+        FROM (
+            SELECT v.id, v.created_at,
+                upper(trim(BOTH '-' FROM regexp_replace(p.slug || '-' || COALESCE(v.color, '') || '-' || COALESCE(v.size, ''), '[^a-zA-Z0-9]+', '-', 'g'))) AS base
+            FROM product_variants v
+            JOIN products p ON p.id = v.product_id
+        ) bases
+    ) numbered
+) s
+WHERE product_variants.id = s.id;
+
+UPDATE product_variants SET sku = 'SKU-' || upper(replace(id::text, '-', '')) WHERE sku IS NULL;
+
+ALTER TABLE product_variants ALTER COLUMN sku SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_product_variants_sku ON product_variants (sku);
+-- UPC-A codes are EAN-13 codes with a leading zero, so compare them as GTIN-14
+CREATE UNIQUE INDEX idx_product_variants_barcode ON product_variants (lpad(barcode, 14, '0')) WHERE barcode IS NOT NULL;
+CREATE UNIQUE INDEX idx_product_variants_options ON product_variants (product_id, lower(size), lower(color)) WHERE is_deleted = false;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_product_variants_options;
+ALTER TABLE product_variants DROP COLUMN IF EXISTS options_key;
+CREATE UNIQUE INDEX idx_product_variants_options ON product_variants (product_id, lower(size), lower(color)) WHERE is_deleted = false;
+
+DROP TABLE IF EXISTS product_attributes;
+DROP TABLE IF EXISTS variant_option_values;
+DROP TABLE IF EXISTS category_option_types;
+DROP TABLE IF EXISTS option_types;
@@ -0,0 +1,61 @@
+-- OPTION TYPES
+-- Size and Color are built in and stored on product_variants; other option types
+-- such as Inseam or Material are stored in variant_option_values.
+CREATE TABLE option_types (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    created_by UUID,
+    updated_by UUID,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    is_deleted BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX idx_option_types_name ON option_types (lower(name)) WHERE is_deleted = false;
+
+INSERT INTO option_types (name) VALUES ('Size'), ('Color');
+
+-- Option types offered by a category; subcategories inherit them
+CREATE TABLE category_option_types (
+    category_id UUID NOT NULL REFERENCES categories(id),
+    option_type_id UUID NOT NULL REFERENCES option_types(id),
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (category_id, option_type_id)
+);
+
+CREATE TABLE variant_option_values (
+    variant_id UUID NOT NULL REFERENCES product_variants(id),
+    option_type_id UUID NOT NULL REFERENCES option_types(id),
+    value TEXT NOT NULL,
+    PRIMARY KEY (variant_id, option_type_id)
+);
+
+CREATE INDEX idx_variant_option_values_value ON variant_option_values (option_type_id, lower(value));
+
+-- PRODUCT ATTRIBUTES, e.g. fabric, care instructions or country of origin
+CREATE TABLE product_attributes (
+    product_id UUID NOT NULL REFERENCES products(id),
+    name TEXT NOT NULL,
+    value TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_product_attributes_name ON product_attributes (product_id, lower(name));
+CREATE INDEX idx_product_attributes_value ON product_attributes (lower(name), lower(value));
+
+-- Variants are unique by all of their option values, not only size and color
+ALTER TABLE product_variants ADD COLUMN options_key TEXT;
+
+-- The key joins the trimmed, lower-cased size, color and name=value pairs of the
+-- other options, which are sorted byte-wise by their current option type name as
+-- the application sorts them
+UPDATE product_variants v
+SET options_key = lower(btrim(COALESCE(v.size, ''))) || chr(31) || lower(btrim(COALESCE(v.color, ''))) || COALESCE((
+    SELECT string_agg(chr(31) || lower(btrim(ot.name)) || '=' || lower(btrim(vov.value)), '' ORDER BY lower(btrim(ot.name)) COLLATE "C")
+    FROM variant_option_values vov
+    JOIN option_types ot ON ot.id = vov.option_type_id
+    WHERE vov.variant_id = v.id
+), '');
+
+DROP INDEX IF EXISTS idx_product_variants_options;
+CREATE UNIQUE INDEX idx_product_variants_options ON product_variants (product_id, options_key) WHERE is_deleted = false;
@@ -0,0 +1,12 @@
+-- FULL-TEXT SEARCH
+-- Weighted document over the product name (A), brand and category names (B) and
+-- description (C). The repositories refresh it whenever one of those changes.
+ALTER TABLE products ADD COLUMN search_vector TSVECTOR NOT NULL DEFAULT ''::tsvector;
+
+UPDATE products p SET search_vector =
+    setweight(to_tsvector('english', COALESCE(p.name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE((SELECT b.name FROM brands b WHERE b.id = p.brand_id), '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE((SELECT c.name FROM categories c WHERE c.id = p.category_id), '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(p.description, '')), 'C');
+
+CREATE INDEX idx_products_search ON products USING GIN (search_vector);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_security_events_created_at_id;
+CREATE INDEX idx_security_events_created_at ON security_events(created_at DESC);
+
+DROP INDEX IF EXISTS idx_users_created_at_id;
+DROP INDEX IF EXISTS idx_products_created_at_id;
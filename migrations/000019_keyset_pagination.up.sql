@@ -0,0 +1,8 @@
+-- KEYSET PAGINATION
+-- Listings are ordered newest first by (created_at, id), and cursor pages start
+-- after the last row of the previous page.
+CREATE INDEX idx_products_created_at_id ON products(created_at DESC, id DESC);
+CREATE INDEX idx_users_created_at_id ON users(created_at DESC, id DESC);
+
+DROP INDEX IF EXISTS idx_security_events_created_at;
+CREATE INDEX idx_security_events_created_at_id ON security_events(created_at DESC, id DESC);
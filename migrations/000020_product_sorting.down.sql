@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_products_rating;
+DROP INDEX IF EXISTS idx_products_sold_count;
+DROP INDEX IF EXISTS idx_products_min_price;
+
+ALTER TABLE products DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE products DROP COLUMN IF EXISTS rating_avg;
+ALTER TABLE products DROP COLUMN IF EXISTS sold_count;
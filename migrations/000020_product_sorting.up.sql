@@ -0,0 +1,12 @@
+-- PRODUCT SORTING
+-- Units sold and the average of the product ratings, kept on the product so
+-- best-selling and top-rated listings can be sorted without aggregating. They
+-- stay 0 until orders and reviews maintain them; products then keep their
+-- order by ID.
+ALTER TABLE products ADD COLUMN sold_count INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN rating_avg NUMERIC(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN rating_count INT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_products_min_price ON products(min_price);
+CREATE INDEX idx_products_sold_count ON products(sold_count);
+CREATE INDEX idx_products_rating ON products(rating_avg, rating_count);